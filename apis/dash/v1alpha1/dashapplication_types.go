@@ -1,6 +1,12 @@
 package v1alpha1
 
-import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
 
 func init() {
 	SchemeBuilder.Register(&DashApplication{}, &DashApplicationList{})
@@ -12,6 +18,7 @@ type DashApplicationSpec struct {
 	// Number of desired pods. This is a pointer to distinguish between explicit
 	// zero and not specified. Defaults to 1.
 	// +optional
+	// +kubebuilder:validation:Minimum=0
 	Replicas *int32 `json:"replicas,omitempty"`
 	// +optional
 	// Labels for dash deployment
@@ -22,12 +29,273 @@ type DashApplicationSpec struct {
 	// Ingress spec. If not specified only LoadBalancer service is created
 	// +optional
 	Ingress *Ingress `json:"ingress,omitempty"`
+	// Autoscaling creates a HorizontalPodAutoscaler for the application. Replicas is
+	// ignored while autoscaling is enabled.
+	// +optional
+	Autoscaling *Autoscaling `json:"autoscaling,omitempty"`
+	// Idle scales the application to zero after a period without requests. The application
+	// Service is served by the activator, which holds requests while the application is
+	// scaled up again.
+	// +optional
+	Idle *Idle `json:"idle,omitempty"`
+	// Suspend scales the application to zero while keeping all its resources. Resuming
+	// restores the previous number of replicas.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+	// PausedPage points the application Service at a page served by the controller, which
+	// tells visitors the application is paused, while the application is suspended.
+	// +optional
+	PausedPage bool `json:"pausedPage,omitempty"`
+	// Schedule scales the application to zero outside of its up windows.
+	// +optional
+	Schedule *Schedule `json:"schedule,omitempty"`
+	// Source runs the application from Python source instead of an application image. The
+	// source is mounted into the base image, requirements are installed by an init container.
+	// +optional
+	Source *Source `json:"source,omitempty"`
+	// Server runs the Dash server with a production WSGI server instead of the command of the
+	// image. The server has to be installed in the image or listed in the requirements.
+	// +optional
+	Server *Server `json:"server,omitempty"`
+	// Dash configures the Dash app through its environment variables. A Flask secret key is
+	// generated for applications with a dash block.
+	// +optional
+	Dash *DashConfig `json:"dash,omitempty"`
+	// Volumes which can be mounted by the application container with
+	// spec.container.volumeMounts.
+	// +optional
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+	// Storage creates a PersistentVolumeClaim owned by the application and mounts it into the
+	// application container. The claim is deleted with the application.
+	// +optional
+	Storage *Storage `json:"storage,omitempty"`
+	// NodeSelector is a selector which must match the labels of the nodes the application pods
+	// are scheduled on.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations of the application pods.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Affinity is the node and pod affinity of the application pods.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// TopologySpreadConstraints describe how the application pods are spread across topology
+	// domains. Applications running more than one replica are spread across zones by
+	// default, as set in the controller configuration. Constraints without label selector
+	// select the application pods.
+	// +optional
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// PriorityClassName is the priority class of the application pods.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// SecurityContext is the pod security context. Unless DisableSecurityDefaults is set,
+	// unset fields default to the restricted Pod Security Standard: runAsNonRoot and the
+	// RuntimeDefault seccomp profile.
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+	// DisableSecurityDefaults turns off the restricted security context defaults of the pod
+	// and container, and the writable volumes mounted for the read-only root filesystem.
+	// +optional
+	DisableSecurityDefaults bool `json:"disableSecurityDefaults,omitempty"`
+	// DisruptionBudget configures the PodDisruptionBudget created while the application runs
+	// more than one replica. Defaults to a maximum of one unavailable pod.
+	// +optional
+	DisruptionBudget *DisruptionBudget `json:"disruptionBudget,omitempty"`
+}
+
+// DashConfig is translated into the DASH_* environment variables read by the Dash constructor.
+// Settings passed to the constructor in the application code take precedence.
+type DashConfig struct {
+	// RequestsPathnamePrefix is the prefix of the requests the browser sends, for proxies
+	// rewriting the path in front of the ingress. It has to end with the routes prefix, the
+	// ingress path followed by a slash. Defaults to the routes prefix.
+	// +optional
+	RequestsPathnamePrefix string `json:"requestsPathnamePrefix,omitempty"`
+	// Debug enables the Dash dev tools. Never enable it for public applications.
+	// +optional
+	Debug *bool `json:"debug,omitempty"`
+	// ServeLocally serves the Dash JavaScript and CSS from the application instead of a CDN.
+	// +optional
+	ServeLocally *bool `json:"serveLocally,omitempty"`
+	// Compress gzip compresses the responses of the application.
+	// +optional
+	Compress *bool `json:"compress,omitempty"`
+	// AssetsURLPath is the URL path the assets folder is served at, relative to the requests
+	// prefix. Defaults to assets.
+	// +optional
+	AssetsURLPath string `json:"assetsUrlPath,omitempty"`
+	// AssetsExternalPath is the URL the assets folder is served from, e.g. a CDN.
+	// +optional
+	AssetsExternalPath string `json:"assetsExternalPath,omitempty"`
+	// SecretKeyRef selects the Flask secret key, exposed as SECRET_KEY. Defaults to a key
+	// generated into the Secret <name>-secret-key.
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// ServerType is a WSGI server.
+// +kubebuilder:validation:Enum=gunicorn;waitress
+type ServerType string
+
+const (
+	ServerTypeGunicorn ServerType = "gunicorn"
+	ServerTypeWaitress ServerType = "waitress"
+)
+
+// Server configures the WSGI server serving the Flask server of the Dash app.
+type Server struct {
+	// Type of the WSGI server, gunicorn or waitress.
+	Type ServerType `json:"type"`
+	// App is the WSGI application as module:variable, e.g. app:server for a module app.py
+	// with server = app.server. Defaults to app:server.
+	// +optional
+	App string `json:"app,omitempty"`
+	// Workers is the number of gunicorn worker processes. Not supported by waitress.
+	// +optional
+	Workers *int32 `json:"workers,omitempty"`
+	// Threads is the number of threads handling requests, per worker for gunicorn.
+	// +optional
+	Threads *int32 `json:"threads,omitempty"`
+	// Timeout after which gunicorn restarts silent workers and waitress closes inactive
+	// connections.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// Source is the Python source of an application. Exactly one of Inline, ConfigMapRef and Git
+// must be set.
+type Source struct {
+	// Inline is the source written into a ConfigMap generated by the controller.
+	// +optional
+	Inline *InlineSource `json:"inline,omitempty"`
+	// ConfigMapRef references a ConfigMap in the application namespace with the keys app.py
+	// and, optionally, requirements.txt.
+	// +optional
+	ConfigMapRef *corev1.LocalObjectReference `json:"configMapRef,omitempty"`
+	// Git syncs the source from a git repository. A git-sync sidecar pulls new commits, the
+	// application is restarted with the new commit and its requirements.
+	// +optional
+	Git *GitSource `json:"git,omitempty"`
+}
+
+type GitSource struct {
+	// Repository is the URL of the repository, e.g. https://github.com/org/dashboards.git,
+	// git@github.com:org/dashboards.git or file:///repos/dashboards.git for a repository
+	// mounted with spec.volumes.
+	Repository string `json:"repository"`
+	// Ref is the branch, tag or commit to deploy. Defaults to HEAD, the default branch.
+	// +optional
+	Ref string `json:"ref,omitempty"`
+	// SubPath is the directory of app.py and requirements.txt in the repository. Defaults to
+	// the repository root.
+	// +optional
+	SubPath string `json:"subPath,omitempty"`
+	// CredentialsSecretRef references a Secret with the keys username and password for HTTPS
+	// repositories, or ssh-privatekey and known_hosts for SSH repositories.
+	// +optional
+	CredentialsSecretRef *corev1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+	// Period is how often the repository is checked for new commits. Defaults to 1m.
+	// +optional
+	Period *metav1.Duration `json:"period,omitempty"`
+}
+
+type InlineSource struct {
+	// App is the content of app.py, it is started with python and has to serve the Dash app
+	// on the HOST and PORT environment variables, as app.run does by default.
+	App string `json:"app"`
+	// Requirements is the content of requirements.txt, installed with pip before the
+	// application starts. It has to list dash unless the base image contains it.
+	// +optional
+	Requirements string `json:"requirements,omitempty"`
+}
+
+// Storage is a PersistentVolumeClaim provisioned for the application.
+type Storage struct {
+	// Size is the requested capacity. Increasing it expands the volume if the storage class
+	// allows volume expansion.
+	Size resource.Quantity `json:"size"`
+	// MountPath is the path the volume is mounted at in the application container.
+	MountPath string `json:"mountPath"`
+	// StorageClassName is the storage class of the claim. Defaults to the default storage
+	// class of the cluster.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// AccessModes of the claim. Defaults to ReadWriteOnce, in which case the Deployment uses
+	// the Recreate strategy.
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+}
+
+// DisruptionBudget limits the number of pods evicted at once, e.g. while nodes are drained. At
+// most one of MinAvailable and MaxUnavailable may be set.
+type DisruptionBudget struct {
+	// MinAvailable is the number or percentage of pods which must stay available.
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// MaxUnavailable is the number or percentage of pods which may be unavailable.
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+type Schedule struct {
+	// TimeZone is the IANA name of the time zone the cron expressions are evaluated in,
+	// e.g. Europe/Berlin. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+	// Windows during which the application runs. The application is scaled to zero while
+	// no window is up.
+	// +kubebuilder:validation:MinItems=1
+	Windows []ScheduleWindow `json:"windows"`
+}
+
+type ScheduleWindow struct {
+	// Up is the cron expression of the times the window starts, e.g. "0 8 * * 1-5".
+	Up string `json:"up"`
+	// Down is the cron expression of the times the window ends, e.g. "0 18 * * 1-5".
+	Down string `json:"down"`
+}
+
+type Idle struct {
+	// After is the period without requests after which the application is scaled to zero.
+	// Defaults to the controller configuration, 30 minutes unless configured otherwise.
+	// +optional
+	After *metav1.Duration `json:"after,omitempty"`
+}
+
+type Autoscaling struct {
+	// MinReplicas is the lower limit for the number of replicas. Defaults to 1.
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas is the upper limit for the number of replicas.
+	MaxReplicas int32 `json:"maxReplicas"`
+	// TargetCPUUtilizationPercentage is the target average CPU utilization of the pods in
+	// percent of the requested CPU.
+	// +optional
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+	// TargetMemoryUtilizationPercentage is the target average memory utilization of the pods
+	// in percent of the requested memory.
+	// +optional
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+	// Metrics are additional metrics used to compute the desired replica count, e.g.
+	// pod or external metrics. The HorizontalPodAutoscaler defaults to 80% CPU
+	// utilization when no metric is set.
+	// +optional
+	Metrics []autoscalingv2.MetricSpec `json:"metrics,omitempty"`
+	// Behavior configures the scaling behavior in both up and down directions.
+	// +optional
+	Behavior *autoscalingv2.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty"`
 }
 
 // A single application container that you want to run.
 type Container struct {
-	// Image name
-	Image string `json:"image"`
+	// Image name. Required unless spec.source is set, applications built from source default
+	// to the base image of the controller configuration.
+	// +optional
+	Image string `json:"image,omitempty"`
+	// Image pull policy. One of Always, Never, IfNotPresent.
+	// Defaults to Always if :latest tag is specified, or IfNotPresent otherwise.
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
 	// Entrypoint array. Not executed within a shell.
 	// +optional
 	Command []string `json:"command,omitempty"`
@@ -36,6 +304,45 @@ type Container struct {
 	Args []string `json:"args,omitempty"`
 	// ContainerPort port number for image container
 	ContainerPort int32 `json:"containerPort"`
+	// List of environment variables to set in the container. Variables managed
+	// by the controller, like DASH_ROUTES_PATHNAME_PREFIX, take precedence over
+	// entries with the same name.
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// List of sources to populate environment variables in the container.
+	// Supports Secret and ConfigMap references. Keys defined in Env take
+	// precedence over the ones from EnvFrom.
+	// +optional
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+	// ResourceProfile selects named resource requirements, e.g. small, medium or large,
+	// defined in the controller configuration. Defaults to the controller default profile.
+	// +optional
+	ResourceProfile string `json:"resourceProfile,omitempty"`
+	// Compute resources of the container. Requests and limits set here take precedence
+	// over the values of the resource profile.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Periodic probe of container liveness. Defaults to opening a TCP connection to the
+	// http port. A probe without a handler keeps the default handler.
+	// +optional
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+	// Periodic probe of container service readiness. Defaults to an HTTP GET on the Dash
+	// layout endpoint. A probe without a handler keeps the default handler.
+	// +optional
+	ReadinessProbe *corev1.Probe `json:"readinessProbe,omitempty"`
+	// StartupProbe indicates that the application has successfully initialized. Defaults to
+	// an HTTP GET on the Dash layout endpoint allowing five minutes to start. A probe without
+	// a handler keeps the default handler.
+	// +optional
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+	// VolumeMounts of spec.volumes into the container.
+	// +optional
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+	// SecurityContext of the container. Unless spec.disableSecurityDefaults is set, unset
+	// fields default to a read-only root filesystem with writable /tmp and /var/cache/dash
+	// volumes, no privilege escalation and all capabilities dropped.
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
 }
 
 type Ingress struct {
@@ -102,16 +409,142 @@ type IngressTLS struct {
 	SecretName string `json:"secretName,omitempty"`
 }
 
+const (
+	// DeploymentAvailableCondition is true when the application Deployment has at least one available replica.
+	DeploymentAvailableCondition = "DeploymentAvailable"
+	// ServiceReadyCondition is true when the application Service is created and, for LoadBalancer
+	// services, an external address is assigned.
+	ServiceReadyCondition = "ServiceReady"
+	// IngressAdmittedCondition is true when the application Ingress got an address from the ingress controller.
+	// The condition is not set when no ingress is requested.
+	IngressAdmittedCondition = "IngressAdmitted"
+	// RouteConflictCondition is true when an older application claims an overlapping ingress host and path.
+	// The ingress of the application is not created while the conflict exists.
+	RouteConflictCondition = "RouteConflict"
+	// SuspendedCondition is true while the application is suspended with spec.suspend.
+	SuspendedCondition = "Suspended"
+	// ReadyCondition is true when all other conditions are true and the application serves traffic.
+	ReadyCondition = "Ready"
+)
+
+// IdleState describes whether an application with scale-to-zero enabled is running.
+// +kubebuilder:validation:Enum=Active;Idle;Waking
+type IdleState string
+
+const (
+	// IdleStateActive means the application received requests recently and is running.
+	IdleStateActive IdleState = "Active"
+	// IdleStateIdle means the application is scaled to zero.
+	IdleStateIdle IdleState = "Idle"
+	// IdleStateWaking means a request arrived and the application is scaling up from zero.
+	IdleStateWaking IdleState = "Waking"
+)
+
+type IdleStatus struct {
+	// State of the application.
+	State IdleState `json:"state"`
+	// LastRequestTime is the time the last request was served by the activator.
+	// +optional
+	LastRequestTime *metav1.Time `json:"lastRequestTime,omitempty"`
+	// IdleSince is the time the application was scaled to zero.
+	// +optional
+	IdleSince *metav1.Time `json:"idleSince,omitempty"`
+	// ActivatorPort is the activator port serving the application.
+	// +optional
+	ActivatorPort int32 `json:"activatorPort,omitempty"`
+}
+
+type SourceStatus struct {
+	// Commit is the SHA of the commit the application pods run. It is only updated once all
+	// running pods run the same commit.
+	// +optional
+	Commit string `json:"commit,omitempty"`
+}
+
+type ScheduleStatus struct {
+	// Up is true while a schedule window is up and the application runs.
+	Up bool `json:"up"`
+	// NextTransitionTime is the next time a schedule window starts or ends.
+	// +optional
+	NextTransitionTime *metav1.Time `json:"nextTransitionTime,omitempty"`
+}
+
 type DashApplicationStatus struct {
 	// Ready is true when the provider resource is ready.
 	// +optional
 	Ready bool `json:"ready"`
+	// ObservedGeneration is the most recent generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Replicas is the number of application pods, it backs the scale subresource.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+	// Selector is the label selector of the application pods in string form, it backs
+	// the scale subresource.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+	// ReadyReplicas is the number of ready application pods.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+	// AvailableReplicas is the number of available application pods.
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+	// URL the application is reachable on. It is built from the ingress host, path and TLS
+	// configuration, or the load balancer address of the service when no ingress is used.
+	// +optional
+	URL string `json:"url,omitempty"`
+	// ServiceType is the type of the application Service. It changes from LoadBalancer
+	// to ClusterIP when an ingress is added and back when it is removed.
+	// +optional
+	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
+	// Reason is a brief CamelCase explanation why the application is not ready.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Conditions represent the latest available observations of the application state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ResourceProfile is the resource profile the container resources are based on.
+	// +optional
+	ResourceProfile string `json:"resourceProfile,omitempty"`
+	// Resources are the effective compute resources of the application container.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Idle reports the scale-to-zero state when spec.idle is set.
+	// +optional
+	Idle *IdleStatus `json:"idle,omitempty"`
+	// Schedule reports the state of spec.schedule.
+	// +optional
+	Schedule *ScheduleStatus `json:"schedule,omitempty"`
+	// Source reports the source of applications run from git.
+	// +optional
+	Source *SourceStatus `json:"source,omitempty"`
+	// SuspendedReplicas is the number of replicas of the Deployment when the application was
+	// suspended. They are restored when the application is resumed.
+	// +optional
+	SuspendedReplicas *int32 `json:"suspendedReplicas,omitempty"`
+	// ConfigHash is the hash of the referenced Secrets and ConfigMaps
+	// the running pod template was generated from.
+	// +optional
+	ConfigHash string `json:"configHash,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:scope=Namespaced
 // +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Application ready status"
+// +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".spec.replicas",description="Desired number of pods"
+// +kubebuilder:printcolumn:name="Available",type="integer",JSONPath=".status.availableReplicas",description="Number of available pods"
+// +kubebuilder:printcolumn:name="URL",type="string",JSONPath=".status.url",description="Application URL"
+// +kubebuilder:printcolumn:name="Image",type="string",JSONPath=".spec.container.image",description="Application image"
+// +kubebuilder:printcolumn:name="Profile",type="string",JSONPath=".status.resourceProfile",description="Resource profile of the application container",priority=1
+// +kubebuilder:printcolumn:name="Idle",type="string",JSONPath=".status.idle.state",description="Scale-to-zero state",priority=1
+// +kubebuilder:printcolumn:name="Next Transition",type="date",JSONPath=".status.schedule.nextTransitionTime",description="Next scheduled scale up or down",priority=1
+// +kubebuilder:printcolumn:name="Commit",type="string",JSONPath=".status.source.commit",description="Commit of applications run from git",priority=1
+// +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.reason",description="Reason the application is not ready",priority=1
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 type DashApplication struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
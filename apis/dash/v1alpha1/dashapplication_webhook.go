@@ -0,0 +1,566 @@
+package v1alpha1
+
+import (
+	"fmt"
+	pathpkg "path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pluralsh/dash-controller/pkg/cron"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// NameLabel is set on all resources generated for a DashApplication and is used as pod selector.
+const NameLabel = "dash.plural.sh/name"
+
+// Names of the volumes added by the controller, user defined volumes can't use them.
+const (
+	// StorageVolumeName mounts the PersistentVolumeClaim of spec.storage.
+	StorageVolumeName = "storage"
+	// TmpVolumeName and CacheVolumeName are writable directories of the security defaults.
+	TmpVolumeName   = "tmp"
+	CacheVolumeName = "cache"
+	// SourceVolumeName and PackagesVolumeName hold the source and installed requirements of
+	// applications run from spec.source.
+	SourceVolumeName   = "source"
+	PackagesVolumeName = "packages"
+	// GitCredentialsVolumeName mounts the credentials Secret of git source.
+	GitCredentialsVolumeName = "git-credentials"
+)
+
+// Keys of the source ConfigMap.
+const (
+	SourceAppKey          = "app.py"
+	SourceRequirementsKey = "requirements.txt"
+)
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks with the manager.
+// The defaulting webhook uses the Ignore failure policy, the controller applies the same defaults
+// when generating the resources. The validating webhook fails closed, as applications created
+// while the controller is unreachable would not be validated at all.
+func (in *DashApplication) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(in).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-dash-plural-sh-v1alpha1-dashapplication,mutating=true,failurePolicy=ignore,sideEffects=None,groups=dash.plural.sh,resources=dashapplications,verbs=create;update,versions=v1alpha1,name=mdashapplication.dash.plural.sh,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &DashApplication{}
+
+// Default implements webhook.Defaulter.
+func (in *DashApplication) Default() {
+	if in.Spec.Replicas == nil {
+		replicas := int32(1)
+		in.Spec.Replicas = &replicas
+	}
+	if in.Spec.Ingress != nil && in.Spec.Ingress.Path == "" {
+		in.Spec.Ingress.Path = "/"
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-dash-plural-sh-v1alpha1-dashapplication,mutating=false,failurePolicy=fail,sideEffects=None,groups=dash.plural.sh,resources=dashapplications,verbs=create;update,versions=v1alpha1,name=vdashapplication.dash.plural.sh,admissionReviewVersions=v1
+
+var _ webhook.Validator = &DashApplication{}
+
+// ValidateCreate implements webhook.Validator.
+func (in *DashApplication) ValidateCreate() error {
+	return in.validate()
+}
+
+// ValidateUpdate implements webhook.Validator. Updates of deleted objects and updates which
+// leave the spec unchanged, e.g. finalizer removals, are always allowed. Otherwise only errors
+// the old object didn't have are reported, so objects created before a validation rule was
+// introduced stay updatable.
+func (in *DashApplication) ValidateUpdate(old runtime.Object) error {
+	if in.DeletionTimestamp != nil {
+		return nil
+	}
+	oldApp, ok := old.(*DashApplication)
+	if !ok {
+		return in.validate()
+	}
+	if equality.Semantic.DeepEqual(in.Spec, oldApp.Spec) {
+		return nil
+	}
+
+	existing := map[string]bool{}
+	for _, err := range oldApp.validationErrors() {
+		existing[errorKey(err)] = true
+	}
+	var allErrs field.ErrorList
+	for _, err := range in.validationErrors() {
+		if !existing[errorKey(err)] {
+			allErrs = append(allErrs, err)
+		}
+	}
+	return in.invalid(allErrs)
+}
+
+// errorKey identifies a validation error by field, type and value, so an error stays the same
+// while the field is unchanged.
+func errorKey(err *field.Error) string {
+	return fmt.Sprintf("%s/%s/%v", err.Field, err.Type, err.BadValue)
+}
+
+// ValidateDelete implements webhook.Validator.
+func (in *DashApplication) ValidateDelete() error {
+	return nil
+}
+
+func (in *DashApplication) validate() error {
+	return in.invalid(in.validationErrors())
+}
+
+func (in *DashApplication) invalid(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("DashApplication").GroupKind(), in.Name, allErrs)
+}
+
+func (in *DashApplication) validationErrors() field.ErrorList {
+	var allErrs field.ErrorList
+
+	// The name is used for the generated Deployment, Service and Ingress.
+	for _, msg := range validation.IsDNS1035Label(in.Name) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "name"), in.Name, msg))
+	}
+
+	allErrs = append(allErrs, in.Spec.validate(field.NewPath("spec"))...)
+	return allErrs
+}
+
+func (in *DashApplicationSpec) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if in.Replicas != nil && *in.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("replicas"), *in.Replicas, "must be greater than or equal to 0"))
+	}
+
+	allErrs = append(allErrs, metav1validation.ValidateLabels(in.Labels, path.Child("labels"))...)
+	if _, ok := in.Labels[NameLabel]; ok {
+		allErrs = append(allErrs, field.Forbidden(path.Child("labels").Key(NameLabel), "label is managed by the controller"))
+	}
+
+	allErrs = append(allErrs, in.Container.validate(path.Child("container"))...)
+	if in.Dash != nil {
+		allErrs = append(allErrs, in.Dash.validate(path.Child("dash"), in.Ingress)...)
+	}
+	if in.Server != nil {
+		allErrs = append(allErrs, in.Server.validate(path.Child("server"))...)
+		if len(in.Container.Command) > 0 {
+			allErrs = append(allErrs, field.Forbidden(path.Child("container", "command"), "may not be set together with server"))
+		}
+	}
+	if in.Source != nil {
+		allErrs = append(allErrs, in.Source.validate(path.Child("source"))...)
+	} else if strings.TrimSpace(in.Container.Image) == "" {
+		allErrs = append(allErrs, field.Required(path.Child("container", "image"), "required unless source is set"))
+	}
+	if in.Ingress != nil {
+		allErrs = append(allErrs, in.Ingress.validate(path.Child("ingress"))...)
+	}
+	if in.Autoscaling != nil {
+		allErrs = append(allErrs, in.Autoscaling.validate(path.Child("autoscaling"))...)
+	}
+	if in.Schedule != nil {
+		allErrs = append(allErrs, in.Schedule.validate(path.Child("schedule"))...)
+	}
+	allErrs = append(allErrs, metav1validation.ValidateLabels(in.NodeSelector, path.Child("nodeSelector"))...)
+	for i, constraint := range in.TopologySpreadConstraints {
+		if constraint.MaxSkew < 1 {
+			allErrs = append(allErrs, field.Invalid(path.Child("topologySpreadConstraints").Index(i).Child("maxSkew"), constraint.MaxSkew, "must be greater than 0"))
+		}
+		if constraint.TopologyKey == "" {
+			allErrs = append(allErrs, field.Required(path.Child("topologySpreadConstraints").Index(i).Child("topologyKey"), ""))
+		}
+	}
+	if in.PriorityClassName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(in.PriorityClassName) {
+			allErrs = append(allErrs, field.Invalid(path.Child("priorityClassName"), in.PriorityClassName, msg))
+		}
+	}
+	allErrs = append(allErrs, in.validateVolumes(path)...)
+	if in.DisruptionBudget != nil {
+		allErrs = append(allErrs, in.DisruptionBudget.validate(path.Child("disruptionBudget"))...)
+	}
+	if in.Idle != nil && in.Idle.After != nil && in.Idle.After.Duration < time.Minute {
+		allErrs = append(allErrs, field.Invalid(path.Child("idle", "after"), in.Idle.After.Duration.String(), "must be at least 1m"))
+	}
+
+	return allErrs
+}
+
+func (in *Container) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for _, msg := range validation.IsValidPortNum(int(in.ContainerPort)) {
+		allErrs = append(allErrs, field.Invalid(path.Child("containerPort"), in.ContainerPort, msg))
+	}
+
+	switch in.ImagePullPolicy {
+	case "", corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+	default:
+		allErrs = append(allErrs, field.NotSupported(path.Child("imagePullPolicy"), in.ImagePullPolicy,
+			[]string{string(corev1.PullAlways), string(corev1.PullIfNotPresent), string(corev1.PullNever)}))
+	}
+
+	for i, env := range in.Env {
+		for _, msg := range validation.IsEnvVarName(env.Name) {
+			allErrs = append(allErrs, field.Invalid(path.Child("env").Index(i).Child("name"), env.Name, msg))
+		}
+	}
+	for i, envFrom := range in.EnvFrom {
+		if (envFrom.SecretRef == nil) == (envFrom.ConfigMapRef == nil) {
+			allErrs = append(allErrs, field.Invalid(path.Child("envFrom").Index(i), "", "exactly one of secretRef or configMapRef must be set"))
+		}
+	}
+
+	// Whether the profile exists is only known to the controller, it reports unknown profiles in the status.
+	if in.ResourceProfile != "" {
+		for _, msg := range validation.IsDNS1123Label(in.ResourceProfile) {
+			allErrs = append(allErrs, field.Invalid(path.Child("resourceProfile"), in.ResourceProfile, msg))
+		}
+	}
+	allErrs = append(allErrs, validateResources(path.Child("resources"), in.Resources)...)
+	allErrs = append(allErrs, validateProbe(path.Child("livenessProbe"), in.LivenessProbe)...)
+	allErrs = append(allErrs, validateProbe(path.Child("readinessProbe"), in.ReadinessProbe)...)
+	allErrs = append(allErrs, validateProbe(path.Child("startupProbe"), in.StartupProbe)...)
+
+	return allErrs
+}
+
+func validateResources(path *field.Path, resources corev1.ResourceRequirements) field.ErrorList {
+	var allErrs field.ErrorList
+	for name, quantity := range resources.Requests {
+		if quantity.Sign() < 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("requests").Key(string(name)), quantity.String(), "must be greater than or equal to 0"))
+		}
+		if limit, ok := resources.Limits[name]; ok && quantity.Cmp(limit) > 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("requests").Key(string(name)), quantity.String(), fmt.Sprintf("must be less than or equal to %s limit of %s", name, limit.String())))
+		}
+	}
+	for name, quantity := range resources.Limits {
+		if quantity.Sign() < 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("limits").Key(string(name)), quantity.String(), "must be greater than or equal to 0"))
+		}
+	}
+	return allErrs
+}
+
+// validateProbe checks the probe sets at most one handler. Probes without handler use the
+// default Dash handler.
+func validateProbe(path *field.Path, probe *corev1.Probe) field.ErrorList {
+	if probe == nil {
+		return nil
+	}
+	handlers := 0
+	for _, set := range []bool{probe.HTTPGet != nil, probe.TCPSocket != nil, probe.Exec != nil, probe.GRPC != nil} {
+		if set {
+			handlers++
+		}
+	}
+	if handlers > 1 {
+		return field.ErrorList{field.Forbidden(path, "may not specify more than one handler type")}
+	}
+	return nil
+}
+
+// validateVolumes checks the volume names are unique and not used by the controller, and the
+// volume mounts reference a volume.
+func (in *DashApplicationSpec) validateVolumes(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	reserved := map[string]bool{}
+	if in.Storage != nil {
+		reserved[StorageVolumeName] = true
+	}
+	if !in.DisableSecurityDefaults {
+		reserved[TmpVolumeName] = true
+		reserved[CacheVolumeName] = true
+	}
+	if in.Source != nil {
+		reserved[SourceVolumeName] = true
+		reserved[PackagesVolumeName] = true
+		reserved[GitCredentialsVolumeName] = in.Source.Git != nil && in.Source.Git.CredentialsSecretRef != nil
+	}
+	names := map[string]bool{}
+	for i, volume := range in.Volumes {
+		volumePath := path.Child("volumes").Index(i).Child("name")
+		for _, msg := range validation.IsDNS1123Label(volume.Name) {
+			allErrs = append(allErrs, field.Invalid(volumePath, volume.Name, msg))
+		}
+		switch {
+		case reserved[volume.Name]:
+			allErrs = append(allErrs, field.Forbidden(volumePath, fmt.Sprintf("volume name %q is used by the controller", volume.Name)))
+		case names[volume.Name]:
+			allErrs = append(allErrs, field.Duplicate(volumePath, volume.Name))
+		}
+		names[volume.Name] = true
+	}
+	if in.Storage != nil {
+		names[StorageVolumeName] = true
+		allErrs = append(allErrs, in.Storage.validate(path.Child("storage"))...)
+	}
+
+	mountPaths := map[string]bool{}
+	if in.Storage != nil {
+		mountPaths[in.Storage.MountPath] = true
+	}
+	for i, mount := range in.Container.VolumeMounts {
+		mountPath := path.Child("container", "volumeMounts").Index(i)
+		if !names[mount.Name] {
+			allErrs = append(allErrs, field.NotFound(mountPath.Child("name"), mount.Name))
+		}
+		if !strings.HasPrefix(mount.MountPath, "/") {
+			allErrs = append(allErrs, field.Invalid(mountPath.Child("mountPath"), mount.MountPath, "must be an absolute path"))
+		} else if mountPaths[mount.MountPath] {
+			allErrs = append(allErrs, field.Duplicate(mountPath.Child("mountPath"), mount.MountPath))
+		}
+		mountPaths[mount.MountPath] = true
+	}
+	return allErrs
+}
+
+func (in *DashConfig) validate(path *field.Path, ingress *Ingress) field.ErrorList {
+	var allErrs field.ErrorList
+	if prefix := in.RequestsPathnamePrefix; prefix != "" {
+		routesPrefix := "/"
+		if ingress != nil && ingress.Path != "" {
+			routesPrefix = strings.TrimSuffix(ingress.Path, "/") + "/"
+		}
+		if !strings.HasPrefix(prefix, "/") || !strings.HasSuffix(prefix, routesPrefix) {
+			allErrs = append(allErrs, field.Invalid(path.Child("requestsPathnamePrefix"), prefix, fmt.Sprintf("must begin with '/' and end with the routes prefix %q", routesPrefix)))
+		}
+	}
+	if in.SecretKeyRef != nil && (in.SecretKeyRef.Name == "" || in.SecretKeyRef.Key == "") {
+		allErrs = append(allErrs, field.Required(path.Child("secretKeyRef"), "name and key are required"))
+	}
+	return allErrs
+}
+
+func (in *Server) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch in.Type {
+	case ServerTypeGunicorn, ServerTypeWaitress:
+	default:
+		allErrs = append(allErrs, field.NotSupported(path.Child("type"), in.Type, []string{string(ServerTypeGunicorn), string(ServerTypeWaitress)}))
+	}
+	if in.App != "" {
+		module, variable, found := strings.Cut(in.App, ":")
+		if !found || module == "" || variable == "" {
+			allErrs = append(allErrs, field.Invalid(path.Child("app"), in.App, "must be module:variable"))
+		}
+	}
+	if in.Workers != nil {
+		if in.Type == ServerTypeWaitress {
+			allErrs = append(allErrs, field.Forbidden(path.Child("workers"), "not supported by waitress"))
+		} else if *in.Workers < 1 {
+			allErrs = append(allErrs, field.Invalid(path.Child("workers"), *in.Workers, "must be greater than 0"))
+		}
+	}
+	if in.Threads != nil && *in.Threads < 1 {
+		allErrs = append(allErrs, field.Invalid(path.Child("threads"), *in.Threads, "must be greater than 0"))
+	}
+	if in.Timeout != nil && in.Timeout.Duration < time.Second {
+		allErrs = append(allErrs, field.Invalid(path.Child("timeout"), in.Timeout.Duration.String(), "must be at least 1s"))
+	}
+	return allErrs
+}
+
+func (in *Source) validate(path *field.Path) field.ErrorList {
+	set := 0
+	for _, isSet := range []bool{in.Inline != nil, in.ConfigMapRef != nil, in.Git != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return field.ErrorList{field.Invalid(path, "", "exactly one of inline, configMapRef or git must be set")}
+	}
+	var allErrs field.ErrorList
+	if in.Inline != nil && strings.TrimSpace(in.Inline.App) == "" {
+		allErrs = append(allErrs, field.Required(path.Child("inline", "app"), ""))
+	}
+	if in.ConfigMapRef != nil {
+		for _, msg := range validation.IsDNS1123Subdomain(in.ConfigMapRef.Name) {
+			allErrs = append(allErrs, field.Invalid(path.Child("configMapRef", "name"), in.ConfigMapRef.Name, msg))
+		}
+	}
+	if in.Git != nil {
+		allErrs = append(allErrs, in.Git.validate(path.Child("git"))...)
+	}
+	return allErrs
+}
+
+func (in *GitSource) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if strings.TrimSpace(in.Repository) == "" {
+		allErrs = append(allErrs, field.Required(path.Child("repository"), ""))
+	}
+	if in.SubPath != "" {
+		clean := pathpkg.Clean(in.SubPath)
+		if pathpkg.IsAbs(in.SubPath) || clean == ".." || strings.HasPrefix(clean, "../") {
+			allErrs = append(allErrs, field.Invalid(path.Child("subPath"), in.SubPath, "must be a relative path within the repository"))
+		}
+	}
+	if in.CredentialsSecretRef != nil {
+		for _, msg := range validation.IsDNS1123Subdomain(in.CredentialsSecretRef.Name) {
+			allErrs = append(allErrs, field.Invalid(path.Child("credentialsSecretRef", "name"), in.CredentialsSecretRef.Name, msg))
+		}
+	}
+	if in.Period != nil && in.Period.Duration < time.Second {
+		allErrs = append(allErrs, field.Invalid(path.Child("period"), in.Period.Duration.String(), "must be at least 1s"))
+	}
+	return allErrs
+}
+
+func (in *Storage) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if in.Size.Sign() <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("size"), in.Size.String(), "must be greater than 0"))
+	}
+	if !strings.HasPrefix(in.MountPath, "/") {
+		allErrs = append(allErrs, field.Invalid(path.Child("mountPath"), in.MountPath, "must be an absolute path"))
+	}
+	for i, mode := range in.AccessModes {
+		switch mode {
+		case corev1.ReadWriteOnce, corev1.ReadOnlyMany, corev1.ReadWriteMany, corev1.ReadWriteOncePod:
+		default:
+			allErrs = append(allErrs, field.NotSupported(path.Child("accessModes").Index(i), mode,
+				[]string{string(corev1.ReadWriteOnce), string(corev1.ReadOnlyMany), string(corev1.ReadWriteMany), string(corev1.ReadWriteOncePod)}))
+		}
+	}
+	return allErrs
+}
+
+func (in *DisruptionBudget) validate(path *field.Path) field.ErrorList {
+	if in.MinAvailable != nil && in.MaxUnavailable != nil {
+		return field.ErrorList{field.Invalid(path, "", "minAvailable and maxUnavailable are mutually exclusive")}
+	}
+	var allErrs field.ErrorList
+	if in.MinAvailable != nil {
+		allErrs = append(allErrs, validateIntOrPercent(path.Child("minAvailable"), *in.MinAvailable)...)
+	}
+	if in.MaxUnavailable != nil {
+		allErrs = append(allErrs, validateIntOrPercent(path.Child("maxUnavailable"), *in.MaxUnavailable)...)
+	}
+	return allErrs
+}
+
+// validateIntOrPercent checks the value is a non-negative number or a percentage up to 100%.
+func validateIntOrPercent(path *field.Path, value intstr.IntOrString) field.ErrorList {
+	if value.Type == intstr.Int {
+		if value.IntVal < 0 {
+			return field.ErrorList{field.Invalid(path, value.IntVal, "must be greater than or equal to 0")}
+		}
+		return nil
+	}
+	percent, err := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%"))
+	if !strings.HasSuffix(value.StrVal, "%") || err != nil || percent < 0 || percent > 100 {
+		return field.ErrorList{field.Invalid(path, value.StrVal, "must be a number or a percentage between 0% and 100%")}
+	}
+	return nil
+}
+
+func (in *Autoscaling) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	minReplicas := int32(1)
+	if in.MinReplicas != nil {
+		minReplicas = *in.MinReplicas
+		if minReplicas < 1 {
+			allErrs = append(allErrs, field.Invalid(path.Child("minReplicas"), minReplicas, "must be greater than or equal to 1"))
+		}
+	}
+	if in.MaxReplicas < minReplicas {
+		allErrs = append(allErrs, field.Invalid(path.Child("maxReplicas"), in.MaxReplicas, "must be greater than or equal to minReplicas"))
+	}
+	if in.TargetCPUUtilizationPercentage != nil && *in.TargetCPUUtilizationPercentage < 1 {
+		allErrs = append(allErrs, field.Invalid(path.Child("targetCPUUtilizationPercentage"), *in.TargetCPUUtilizationPercentage, "must be greater than 0"))
+	}
+	if in.TargetMemoryUtilizationPercentage != nil && *in.TargetMemoryUtilizationPercentage < 1 {
+		allErrs = append(allErrs, field.Invalid(path.Child("targetMemoryUtilizationPercentage"), *in.TargetMemoryUtilizationPercentage, "must be greater than 0"))
+	}
+	for i, metric := range in.Metrics {
+		if metric.Type == "" {
+			allErrs = append(allErrs, field.Required(path.Child("metrics").Index(i).Child("type"), ""))
+		}
+	}
+
+	return allErrs
+}
+
+func (in *Schedule) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if _, err := time.LoadLocation(in.TimeZone); err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("timeZone"), in.TimeZone, "unknown time zone"))
+	}
+	if len(in.Windows) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("windows"), "at least one window is required"))
+	}
+	for i, window := range in.Windows {
+		if _, err := cron.Parse(window.Up); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("windows").Index(i).Child("up"), window.Up, err.Error()))
+		}
+		if _, err := cron.Parse(window.Down); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("windows").Index(i).Child("down"), window.Down, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+func (in *Ingress) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if in.Path != "" {
+		if !strings.HasPrefix(in.Path, "/") {
+			allErrs = append(allErrs, field.Invalid(path.Child("path"), in.Path, "must begin with '/'"))
+		}
+		if in.Path != "/" && strings.HasSuffix(in.Path, "/") {
+			allErrs = append(allErrs, field.Invalid(path.Child("path"), in.Path, "must not end with '/'"))
+		}
+		if strings.ContainsAny(in.Path, " ?#") {
+			allErrs = append(allErrs, field.Invalid(path.Child("path"), in.Path, "must not contain spaces, '?' or '#'"))
+		}
+	}
+
+	if in.Host != "" {
+		allErrs = append(allErrs, validateHost(path.Child("host"), in.Host)...)
+	}
+	if in.TLS != nil && in.TLS.Host != "" {
+		allErrs = append(allErrs, validateHost(path.Child("tls", "host"), in.TLS.Host)...)
+	}
+
+	return allErrs
+}
+
+func validateHost(path *field.Path, host string) field.ErrorList {
+	var allErrs field.ErrorList
+	var msgs []string
+	if strings.HasPrefix(host, "*.") {
+		msgs = validation.IsWildcardDNS1123Subdomain(host)
+	} else {
+		msgs = validation.IsDNS1123Subdomain(host)
+	}
+	for _, msg := range msgs {
+		allErrs = append(allErrs, field.Invalid(path, host, msg))
+	}
+	if len(validation.IsValidIP(host)) == 0 {
+		allErrs = append(allErrs, field.Invalid(path, host, "must be a DNS name, not an IP address"))
+	}
+	return allErrs
+}
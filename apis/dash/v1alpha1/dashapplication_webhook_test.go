@@ -0,0 +1,66 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func legacyApp() *DashApplication {
+	return &DashApplication{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Finalizers: []string{"legacy"}},
+		Spec: DashApplicationSpec{
+			Container: Container{Image: "app:latest"},
+			Ingress:   &Ingress{Path: "/app/"},
+		},
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name    string
+		update  func(app *DashApplication)
+		wantErr bool
+	}{
+		{
+			name:   "finalizer removal",
+			update: func(app *DashApplication) { app.Finalizers = nil },
+		},
+		{
+			name: "deletion",
+			update: func(app *DashApplication) {
+				app.DeletionTimestamp = &now
+				app.Spec.Labels = map[string]string{NameLabel: "other"}
+			},
+		},
+		{
+			name:   "unrelated change keeps existing error",
+			update: func(app *DashApplication) { app.Spec.Container.Image = "app:v2" },
+		},
+		{
+			name:    "new error",
+			update:  func(app *DashApplication) { app.Spec.Labels = map[string]string{NameLabel: "other"} },
+			wantErr: true,
+		},
+		{
+			name:    "changed invalid field",
+			update:  func(app *DashApplication) { app.Spec.Ingress.Path = "/other/" },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := legacyApp()
+			if err := old.ValidateCreate(); err == nil {
+				t.Fatal("expected legacy object to be invalid on create")
+			}
+			app := old.DeepCopy()
+			tt.update(app)
+			err := app.ValidateUpdate(old)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,17 +1,27 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	// schedule time zones must not depend on the time zone database of the image
+	_ "time/tzdata"
 
 	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	"github.com/pluralsh/dash-controller/pkg/activator"
+	"github.com/pluralsh/dash-controller/pkg/config"
 	"github.com/pluralsh/dash-controller/pkg/controller"
+	"github.com/pluralsh/dash-controller/pkg/webhook"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	//+kubebuilder:scaffold:imports
 )
@@ -24,19 +34,37 @@ var (
 func init() {
 	utilruntime.Must(dashv1alpha1.AddToScheme(scheme))
 	utilruntime.Must(appsv1.AddToScheme(scheme))
+	utilruntime.Must(autoscalingv2.AddToScheme(scheme))
 	utilruntime.Must(corev1.AddToScheme(scheme))
 	utilruntime.Must(networkingv1.AddToScheme(scheme))
+	utilruntime.Must(policyv1.AddToScheme(scheme))
+	utilruntime.Must(admissionregistrationv1.AddToScheme(scheme))
 }
 
 func main() {
 	var enableLeaderElection bool
 	var metricsAddr string
 	var probeAddr string
+	var enableWebhooks bool
+	var webhookPort int
+	var webhookCertOpts webhook.CertificateOptions
+	var configFile string
+	var activatorAddress string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&configFile, "config", "", "The controller configuration file, e.g. defining resource profiles. Built-in defaults are used when empty.")
+	flag.StringVar(&activatorAddress, "activator-address", os.Getenv("POD_IP"), "The pod IP the activator is reachable on. Scale-to-zero is disabled when empty.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", true, "Serve the DashApplication defaulting and validating webhooks.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port the webhook server binds to.")
+	flag.StringVar(&webhookCertOpts.CertDir, "webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs", "The directory the webhook serving certificate is written to.")
+	flag.StringVar(&webhookCertOpts.Namespace, "webhook-namespace", envOrDefault("POD_NAMESPACE", "dash"), "The namespace of the webhook service and certificate secret.")
+	flag.StringVar(&webhookCertOpts.ServiceName, "webhook-service-name", "dash-controller-webhook", "The name of the webhook service.")
+	flag.StringVar(&webhookCertOpts.SecretName, "webhook-secret-name", "dash-controller-webhook-cert", "The name of the secret storing the webhook certificate.")
+	flag.StringVar(&webhookCertOpts.MutatingWebhookConfiguration, "mutating-webhook-configuration", "dash-controller-mutating", "The name of the MutatingWebhookConfiguration to inject the CA bundle into.")
+	flag.StringVar(&webhookCertOpts.ValidatingWebhookConfiguration, "validating-webhook-configuration", "dash-controller-validating", "The name of the ValidatingWebhookConfiguration to inject the CA bundle into.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -45,21 +73,69 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		setupLog.Error(err, "unable to load controller configuration")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "1237ab00.plural.sh",
 		MetricsBindAddress:     metricsAddr,
 		HealthProbeBindAddress: probeAddr,
+		Port:                   webhookPort,
+		CertDir:                webhookCertOpts.CertDir,
+		// Only the metadata of Secrets is cached, their data is read when an application is
+		// reconciled, see Reconciler.SetupWithManager.
+		ClientDisableCacheFor: []client.Object{&corev1.Secret{}},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to create manager")
 		os.Exit(1)
 	}
 
+	if enableWebhooks {
+		// the manager cache is not started yet, use a direct client to provision the certificates
+		certClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+		if err != nil {
+			setupLog.Error(err, "unable to create client")
+			os.Exit(1)
+		}
+		if err := webhook.EnsureCertificates(context.Background(), certClient, setupLog, webhookCertOpts); err != nil {
+			setupLog.Error(err, "unable to provision webhook certificates")
+			os.Exit(1)
+		}
+		if err := mgr.Add(&webhook.CertificateRenewer{
+			Client:  certClient,
+			Log:     ctrl.Log.WithName("webhook-certificates"),
+			Options: webhookCertOpts,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up webhook certificate renewal")
+			os.Exit(1)
+		}
+		if err := (&dashv1alpha1.DashApplication{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "DashApplication")
+			os.Exit(1)
+		}
+	}
+
+	var dashActivator *activator.Activator
+	if activatorAddress != "" {
+		dashActivator = activator.New(mgr.GetClient(), ctrl.Log.WithName("activator"), cfg.Activator)
+	} else {
+		setupLog.Info("activator address not set, scale-to-zero is disabled")
+	}
+
 	if err = (&controller.Reconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Dash"),
+		Client:           mgr.GetClient(),
+		Log:              ctrl.Log.WithName("controllers").WithName("Dash"),
+		Scheme:           mgr.GetScheme(),
+		Recorder:         mgr.GetEventRecorderFor("dash-controller"),
+		Config:           cfg,
+		Activator:        dashActivator,
+		ActivatorAddress: activatorAddress,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Dash")
 		os.Exit(1)
@@ -73,3 +149,10 @@ func main() {
 	}
 
 }
+
+func envOrDefault(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,345 @@
+package activator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	"github.com/pluralsh/dash-controller/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// backendPollInterval is how often the application pods are checked while requests are held.
+const backendPollInterval = 500 * time.Millisecond
+
+// Activator is the data path of applications with scale-to-zero enabled. Every application
+// gets its own port the application Service is pointed at. Requests are proxied to the ready
+// application pods, while the application is scaled to zero they are held until it is running
+// again. The time of the last request is used by the controller to detect idle applications.
+type Activator struct {
+	client      client.Reader
+	log         logr.Logger
+	minPort     int32
+	maxPort     int32
+	wakeTimeout time.Duration
+	pausedPort  int32
+	wake        chan event.GenericEvent
+
+	mu      sync.Mutex
+	ctx     context.Context
+	apps    map[types.NamespacedName]*app
+	byPort  map[int32]types.NamespacedName
+	stopped bool
+}
+
+type app struct {
+	port        int32
+	server      *http.Server
+	lastRequest time.Time
+	pending     int
+	next        int
+}
+
+// New returns an activator reading the application pods with the given client, usually the
+// cached manager client.
+func New(c client.Reader, log logr.Logger, cfg config.Activator) *Activator {
+	return &Activator{
+		client:      c,
+		log:         log,
+		minPort:     cfg.MinPort,
+		maxPort:     cfg.MaxPort,
+		wakeTimeout: cfg.WakeTimeout.Duration,
+		pausedPort:  cfg.PausedPagePort,
+		wake:        make(chan event.GenericEvent, 128),
+		ctx:         context.Background(),
+		apps:        map[types.NamespacedName]*app{},
+		byPort:      map[int32]types.NamespacedName{},
+	}
+}
+
+// Events returns the channel an event is sent to when a request arrives for an application
+// without ready pods, so the controller scales it up.
+func (a *Activator) Events() <-chan event.GenericEvent {
+	return a.wake
+}
+
+// Register starts serving the application and returns its port. The previously allocated port
+// and last request time are passed in after a controller restart, the port is kept if it is
+// still free.
+func (a *Activator) Register(key types.NamespacedName, port int32, lastRequest time.Time) (int32, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.stopped {
+		return 0, errors.New("activator is stopped")
+	}
+	if existing, ok := a.apps[key]; ok {
+		return existing.port, nil
+	}
+
+	if !a.available(port) {
+		port = 0
+		for candidate := a.minPort; candidate <= a.maxPort; candidate++ {
+			if a.available(candidate) {
+				port = candidate
+				break
+			}
+		}
+		if port == 0 {
+			return 0, fmt.Errorf("no free activator port in range %d-%d", a.minPort, a.maxPort)
+		}
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(int(port))))
+	if err != nil {
+		return 0, err
+	}
+	server := &http.Server{
+		Handler:           a.handler(key),
+		ReadHeaderTimeout: 30 * time.Second,
+		BaseContext:       a.baseContext,
+	}
+	a.apps[key] = &app{port: port, server: server, lastRequest: lastRequest}
+	a.byPort[port] = key
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.log.Error(err, "activator server failed", "application", key, "port", port)
+		}
+	}()
+	a.log.Info("serve application", "application", key, "port", port)
+	return port, nil
+}
+
+// baseContext cancels held requests when the activator is stopped.
+func (a *Activator) baseContext(net.Listener) context.Context {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.ctx
+}
+
+func (a *Activator) available(port int32) bool {
+	if port < a.minPort || port > a.maxPort {
+		return false
+	}
+	_, used := a.byPort[port]
+	return !used
+}
+
+// Unregister stops serving the application and releases its port.
+func (a *Activator) Unregister(key types.NamespacedName) {
+	a.mu.Lock()
+	existing, ok := a.apps[key]
+	if ok {
+		delete(a.apps, key)
+		delete(a.byPort, existing.port)
+	}
+	a.mu.Unlock()
+
+	if ok {
+		a.log.Info("stop serving application", "application", key, "port", existing.port)
+		_ = existing.server.Close()
+	}
+}
+
+// LastRequest returns the time the last request for the application started or finished.
+func (a *Activator) LastRequest(key types.NamespacedName) time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if existing, ok := a.apps[key]; ok {
+		return existing.lastRequest
+	}
+	return time.Time{}
+}
+
+// Pending returns the number of requests for the application currently in flight.
+func (a *Activator) Pending(key types.NamespacedName) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if existing, ok := a.apps[key]; ok {
+		return existing.pending
+	}
+	return 0
+}
+
+// PausedPagePort returns the port the page for suspended applications is served on.
+func (a *Activator) PausedPagePort() int32 {
+	return a.pausedPort
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. The activator only serves on
+// the leader, the controller points the application Services at the leader pod.
+func (a *Activator) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable.
+func (a *Activator) Start(ctx context.Context) error {
+	a.mu.Lock()
+	a.ctx = ctx
+	a.mu.Unlock()
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(int(a.pausedPort))))
+	if err != nil {
+		return fmt.Errorf("failed to serve the paused page: %w", err)
+	}
+	paused := &http.Server{Handler: pausedPageHandler(), ReadHeaderTimeout: 30 * time.Second}
+	go func() {
+		if err := paused.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.log.Error(err, "paused page server failed", "port", a.pausedPort)
+		}
+	}()
+
+	<-ctx.Done()
+	_ = paused.Close()
+
+	a.mu.Lock()
+	a.stopped = true
+	apps := a.apps
+	a.apps = map[types.NamespacedName]*app{}
+	a.byPort = map[int32]types.NamespacedName{}
+	a.mu.Unlock()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, existing := range apps {
+		_ = existing.server.Shutdown(shutdownCtx)
+	}
+	return nil
+}
+
+func (a *Activator) handler(key types.NamespacedName) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		a.track(key, 1)
+		defer a.track(key, -1)
+
+		backend, err := a.waitForBackend(req.Context(), key)
+		if err != nil {
+			a.log.Info("no ready backend", "application", key, "reason", err.Error())
+			w.Header().Set("Retry-After", "10")
+			http.Error(w, "The application is starting, please try again in a moment.", http.StatusServiceUnavailable)
+			return
+		}
+
+		proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: backend})
+		proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+			a.log.Info("proxy request failed", "application", key, "backend", backend, "reason", err.Error())
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		proxy.ServeHTTP(w, req)
+	})
+}
+
+// track records the start and end of a request.
+func (a *Activator) track(key types.NamespacedName, delta int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if existing, ok := a.apps[key]; ok {
+		existing.pending += delta
+		existing.lastRequest = time.Now()
+	}
+}
+
+// waitForBackend returns the address of a ready application pod. When there is none, the
+// controller is notified to scale the application up and the request is held until a pod is
+// ready or the wake timeout expires.
+func (a *Activator) waitForBackend(ctx context.Context, key types.NamespacedName) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.wakeTimeout)
+	defer cancel()
+
+	notified := false
+	ticker := time.NewTicker(backendPollInterval)
+	defer ticker.Stop()
+	for {
+		backend, err := a.backend(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		if backend != "" {
+			return backend, nil
+		}
+		if !notified {
+			a.notify(key)
+			notified = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Activator) notify(key types.NamespacedName) {
+	dashApp := &dashv1alpha1.DashApplication{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+	select {
+	case a.wake <- event.GenericEvent{Object: dashApp}:
+	default:
+		// the controller is busy, the pending request is picked up with the next reconcile
+	}
+}
+
+// backend returns the address of a ready application pod, rotating over all ready pods.
+func (a *Activator) backend(ctx context.Context, key types.NamespacedName) (string, error) {
+	pods := &corev1.PodList{}
+	if err := a.client.List(ctx, pods, client.InNamespace(key.Namespace), client.MatchingLabels{dashv1alpha1.NameLabel: key.Name}); err != nil {
+		return "", err
+	}
+
+	var backends []string
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil || pod.Status.PodIP == "" || !podReady(&pod) {
+			continue
+		}
+		if port := httpPort(&pod); port != 0 {
+			backends = append(backends, net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(port))))
+		}
+	}
+	if len(backends) == 0 {
+		return "", nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	next := 0
+	if existing, ok := a.apps[key]; ok {
+		next = existing.next
+		existing.next++
+	}
+	return backends[next%len(backends)], nil
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// httpPort returns the container port named http, the name the controller gives the application port.
+func httpPort(pod *corev1.Pod) int32 {
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name == "http" {
+				return port.ContainerPort
+			}
+		}
+	}
+	return 0
+}
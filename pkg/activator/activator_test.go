@@ -0,0 +1,296 @@
+package activator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	"github.com/pluralsh/dash-controller/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var appKey = types.NamespacedName{Namespace: "default", Name: "app"}
+
+// freePorts returns the first of n consecutive ports nothing listens on.
+func freePorts(t *testing.T, n int) int32 {
+	t.Helper()
+	for base := 41000; base < 60000; base += n {
+		free := true
+		for port := base; port < base+n && free; port++ {
+			listener, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(port)))
+			if err != nil {
+				free = false
+				continue
+			}
+			_ = listener.Close()
+		}
+		if free {
+			return int32(base)
+		}
+	}
+	t.Fatalf("no %d free consecutive ports", n)
+	return 0
+}
+
+func newTestActivator(t *testing.T, ports int, wakeTimeout time.Duration) (*Activator, client.Client) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	minPort := freePorts(t, ports+1)
+	a := New(c, logr.Discard(), config.Activator{
+		MinPort:        minPort,
+		MaxPort:        minPort + int32(ports) - 1,
+		WakeTimeout:    metav1.Duration{Duration: wakeTimeout},
+		PausedPagePort: minPort + int32(ports),
+	})
+	t.Cleanup(func() {
+		a.mu.Lock()
+		var keys []types.NamespacedName
+		for key := range a.apps {
+			keys = append(keys, key)
+		}
+		a.mu.Unlock()
+		for _, key := range keys {
+			a.Unregister(key)
+		}
+	})
+	return a, c
+}
+
+// readyPod returns a ready application pod serving on the address of the backend server.
+func readyPod(t *testing.T, name string, backend *httptest.Server) *corev1.Pod {
+	t.Helper()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(backendURL.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: appKey.Namespace,
+			Labels:    map[string]string{dashv1alpha1.NameLabel: appKey.Name},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:  appKey.Name,
+			Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: int32(port)}},
+		}}},
+		Status: corev1.PodStatus{
+			PodIP:      backendURL.Hostname(),
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func get(t *testing.T, port int32) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/app/", port))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestRegisterPorts(t *testing.T) {
+	a, _ := newTestActivator(t, 2, time.Second)
+	other := types.NamespacedName{Namespace: "default", Name: "other"}
+	third := types.NamespacedName{Namespace: "default", Name: "third"}
+
+	port, err := a.Register(appKey, 0, time.Time{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if port != a.minPort {
+		t.Errorf("Register() = %d, want the first port %d", port, a.minPort)
+	}
+	if again, err := a.Register(appKey, a.maxPort, time.Time{}); err != nil || again != port {
+		t.Errorf("Register() again = %d, %v, want the allocated port %d", again, err, port)
+	}
+
+	// the previous port is kept after a restart, unless it is used by another application
+	if got, err := a.Register(other, port, time.Time{}); err != nil || got != a.maxPort {
+		t.Errorf("Register() with a used port = %d, %v, want %d", got, err, a.maxPort)
+	}
+	if _, err := a.Register(third, 0, time.Time{}); err == nil {
+		t.Error("Register() expected an error when all ports are allocated")
+	}
+
+	a.Unregister(appKey)
+	if _, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port)))); err == nil {
+		t.Error("port is still served after Unregister()")
+	}
+	if got, err := a.Register(third, port, time.Time{}); err != nil || got != port {
+		t.Errorf("Register() with a released port = %d, %v, want %d", got, err, port)
+	}
+}
+
+func TestHoldAndForward(t *testing.T) {
+	a, c := newTestActivator(t, 1, 10*time.Second)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "served %s", req.URL.Path)
+	}))
+	defer backend.Close()
+
+	registered := time.Now().Add(-time.Hour)
+	port, err := a.Register(appKey, 0, registered)
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/app/", port))
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{resp.StatusCode, string(body), err}
+	}()
+
+	// without a ready pod the controller is asked to scale up and the request is held
+	select {
+	case evt := <-a.Events():
+		if evt.Object.GetName() != appKey.Name || evt.Object.GetNamespace() != appKey.Namespace {
+			t.Errorf("wake event for %s/%s, want %s", evt.Object.GetNamespace(), evt.Object.GetName(), appKey)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no wake event")
+	}
+	if pending := a.Pending(appKey); pending != 1 {
+		t.Errorf("Pending() = %d while the request is held, want 1", pending)
+	}
+
+	if err := c.Create(context.Background(), readyPod(t, "app-1", backend)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case res := <-results:
+		if res.err != nil || res.status != http.StatusOK || res.body != "served /app/" {
+			t.Errorf("response = %d %q (error %v), want the backend response", res.status, res.body, res.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("held request was not forwarded")
+	}
+
+	if pending := a.Pending(appKey); pending != 0 {
+		t.Errorf("Pending() = %d after the request, want 0", pending)
+	}
+	if last := a.LastRequest(appKey); !last.After(registered) {
+		t.Errorf("LastRequest() = %v, want the time of the request", last)
+	}
+
+	// with a ready pod requests are forwarded without waking the application
+	if resp, body := get(t, port); resp.StatusCode != http.StatusOK || body != "served /app/" {
+		t.Errorf("response = %d %q, want the backend response", resp.StatusCode, body)
+	}
+	select {
+	case <-a.Events():
+		t.Error("unexpected wake event with a ready pod")
+	default:
+	}
+}
+
+func TestWakeTimeout(t *testing.T) {
+	a, _ := newTestActivator(t, 1, 100*time.Millisecond)
+	port, err := a.Register(appKey, 0, time.Time{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	resp, _ := get(t, port)
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("response = %d with Retry-After %q, want 503 with Retry-After", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+}
+
+func TestStartShutdown(t *testing.T) {
+	a, _ := newTestActivator(t, 1, 10*time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- a.Start(ctx) }()
+
+	port, err := a.Register(appKey, 0, time.Time{})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	// the paused page is served once started
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/", a.PausedPagePort()))
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("paused page not served: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a held request is answered when the activator stops
+	held := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/", port))
+		if err != nil {
+			held <- 0
+			return
+		}
+		resp.Body.Close()
+		held <- resp.StatusCode
+	}()
+	<-a.Events()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Start() error = %v", err)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("Start() didn't return after the context was cancelled")
+	}
+	select {
+	case status := <-held:
+		if status != http.StatusServiceUnavailable {
+			t.Errorf("held request answered with %d, want 503", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("held request not answered after shutdown")
+	}
+
+	if _, err := a.Register(appKey, 0, time.Time{}); err == nil {
+		t.Error("Register() expected an error after the activator stopped")
+	}
+}
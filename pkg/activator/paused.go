@@ -0,0 +1,35 @@
+package activator
+
+import (
+	"html/template"
+	"net/http"
+)
+
+var pausedPage = template.Must(template.New("paused").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Application paused</title>
+  <style>
+    body { font-family: sans-serif; color: #333; display: flex; align-items: center; justify-content: center; height: 100vh; margin: 0; }
+    main { text-align: center; }
+  </style>
+</head>
+<body>
+  <main>
+    <h1>This application is paused</h1>
+    <p>{{ .Host }} is currently suspended. Please contact the application owner to resume it.</p>
+  </main>
+</body>
+</html>
+`))
+
+// pausedPageHandler answers all requests for suspended applications with the paused page.
+func pausedPageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = pausedPage.Execute(w, struct{ Host string }{Host: req.Host})
+	})
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the controller wide configuration shared by all DashApplications. It is loaded
+// once on startup, changes require a restart of the controller.
+type Config struct {
+	// ResourceProfiles are named resource requirements applications select with
+	// spec.container.resourceProfile instead of writing raw quantities. Profiles defined in
+	// the configuration file replace the built-in profiles with the same name.
+	ResourceProfiles map[string]corev1.ResourceRequirements `json:"resourceProfiles,omitempty"`
+	// DefaultResourceProfile is used for applications which set neither a resource profile
+	// nor resources. No resources are set when empty.
+	DefaultResourceProfile string `json:"defaultResourceProfile,omitempty"`
+	// Activator configures the proxy holding requests of applications scaled to zero.
+	Activator Activator `json:"activator,omitempty"`
+	// TopologySpread is the default spread of the pods of applications running more than one
+	// replica. It applies to applications which set no topology spread constraints.
+	TopologySpread TopologySpread `json:"topologySpread,omitempty"`
+	// Source configures applications run from Python source instead of an application image.
+	Source Source `json:"source,omitempty"`
+}
+
+// Source configures applications run from spec.source.
+type Source struct {
+	// BaseImage is the Python image the source is run in, unless the application sets an image.
+	// It needs pip and sh, the requirements are installed by an init container.
+	BaseImage string `json:"baseImage,omitempty"`
+	// GitSyncImage is the git-sync image syncing the source of applications run from git.
+	// The environment variables of git-sync v4 are used.
+	GitSyncImage string `json:"gitSyncImage,omitempty"`
+}
+
+// TopologySpread configures the default topology spread constraint of the application pods.
+type TopologySpread struct {
+	// Disabled turns the default spread off.
+	Disabled bool `json:"disabled,omitempty"`
+	// TopologyKey is the node label the pods are spread over.
+	TopologyKey string `json:"topologyKey,omitempty"`
+	// MaxSkew is the maximum difference of the number of pods between two topology domains.
+	MaxSkew int32 `json:"maxSkew,omitempty"`
+	// WhenUnsatisfiable is DoNotSchedule or ScheduleAnyway.
+	WhenUnsatisfiable corev1.UnsatisfiableConstraintAction `json:"whenUnsatisfiable,omitempty"`
+}
+
+// Activator configures the activator. Every application with scale-to-zero enabled gets its own
+// activator port, the application Service is pointed at it.
+type Activator struct {
+	// MinPort and MaxPort bound the ports allocated to applications, both inclusive.
+	MinPort int32 `json:"minPort,omitempty"`
+	MaxPort int32 `json:"maxPort,omitempty"`
+	// WakeTimeout is how long requests are held while an application scales up from zero.
+	WakeTimeout metav1.Duration `json:"wakeTimeout,omitempty"`
+	// DefaultIdleAfter is the period without requests after which an application is scaled to
+	// zero when spec.idle.after is not set.
+	DefaultIdleAfter metav1.Duration `json:"defaultIdleAfter,omitempty"`
+	// PausedPagePort is the port the page shown for suspended applications is served on.
+	PausedPagePort int32 `json:"pausedPagePort,omitempty"`
+}
+
+// Default returns the built-in configuration. The profiles only limit memory, CPU limits
+// throttle the single threaded Dash callbacks without protecting the node.
+func Default() *Config {
+	return &Config{
+		ResourceProfiles: map[string]corev1.ResourceRequirements{
+			"small":  resources("100m", "256Mi", "512Mi"),
+			"medium": resources("250m", "512Mi", "1Gi"),
+			"large":  resources("1", "2Gi", "4Gi"),
+		},
+		Activator: Activator{
+			MinPort:          20000,
+			MaxPort:          20999,
+			WakeTimeout:      metav1.Duration{Duration: 2 * time.Minute},
+			DefaultIdleAfter: metav1.Duration{Duration: 30 * time.Minute},
+			PausedPagePort:   8082,
+		},
+		TopologySpread: TopologySpread{
+			TopologyKey:       corev1.LabelTopologyZone,
+			MaxSkew:           1,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+		},
+		Source: Source{
+			BaseImage:    "python:3.11-slim",
+			GitSyncImage: "registry.k8s.io/git-sync/git-sync:v4.1.0",
+		},
+	}
+}
+
+func resources(cpu, memory, memoryLimit string) corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse(memoryLimit),
+		},
+	}
+}
+
+// Load reads the YAML configuration file at path on top of the built-in defaults.
+// The defaults are returned when path is empty.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &Config{}
+	if err := yaml.UnmarshalStrict(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for name, profile := range file.ResourceProfiles {
+		cfg.ResourceProfiles[name] = profile
+	}
+	if file.DefaultResourceProfile != "" {
+		cfg.DefaultResourceProfile = file.DefaultResourceProfile
+	}
+	if file.Activator.MinPort != 0 {
+		cfg.Activator.MinPort = file.Activator.MinPort
+	}
+	if file.Activator.MaxPort != 0 {
+		cfg.Activator.MaxPort = file.Activator.MaxPort
+	}
+	if file.Activator.WakeTimeout.Duration != 0 {
+		cfg.Activator.WakeTimeout = file.Activator.WakeTimeout
+	}
+	if file.Activator.DefaultIdleAfter.Duration != 0 {
+		cfg.Activator.DefaultIdleAfter = file.Activator.DefaultIdleAfter
+	}
+	if file.Activator.PausedPagePort != 0 {
+		cfg.Activator.PausedPagePort = file.Activator.PausedPagePort
+	}
+	if file.TopologySpread.Disabled {
+		cfg.TopologySpread.Disabled = true
+	}
+	if file.TopologySpread.TopologyKey != "" {
+		cfg.TopologySpread.TopologyKey = file.TopologySpread.TopologyKey
+	}
+	if file.TopologySpread.MaxSkew != 0 {
+		cfg.TopologySpread.MaxSkew = file.TopologySpread.MaxSkew
+	}
+	if file.TopologySpread.WhenUnsatisfiable != "" {
+		cfg.TopologySpread.WhenUnsatisfiable = file.TopologySpread.WhenUnsatisfiable
+	}
+	if file.Source.BaseImage != "" {
+		cfg.Source.BaseImage = file.Source.BaseImage
+	}
+	if file.Source.GitSyncImage != "" {
+		cfg.Source.GitSyncImage = file.Source.GitSyncImage
+	}
+
+	return cfg, cfg.validate()
+}
+
+func (c *Config) validate() error {
+	if c.DefaultResourceProfile != "" {
+		if _, ok := c.ResourceProfiles[c.DefaultResourceProfile]; !ok {
+			return fmt.Errorf("default resource profile %q is not defined", c.DefaultResourceProfile)
+		}
+	}
+	if c.Activator.MinPort < 1024 || c.Activator.MaxPort > 65535 || c.Activator.MinPort > c.Activator.MaxPort {
+		return fmt.Errorf("activator port range %d-%d must be within 1024-65535", c.Activator.MinPort, c.Activator.MaxPort)
+	}
+	if c.Activator.PausedPagePort >= c.Activator.MinPort && c.Activator.PausedPagePort <= c.Activator.MaxPort {
+		return fmt.Errorf("paused page port %d must not be in the activator port range", c.Activator.PausedPagePort)
+	}
+	if c.TopologySpread.MaxSkew < 1 {
+		return fmt.Errorf("topology spread maxSkew %d must be at least 1", c.TopologySpread.MaxSkew)
+	}
+	switch c.TopologySpread.WhenUnsatisfiable {
+	case corev1.DoNotSchedule, corev1.ScheduleAnyway:
+	default:
+		return fmt.Errorf("topology spread whenUnsatisfiable must be %s or %s", corev1.DoNotSchedule, corev1.ScheduleAnyway)
+	}
+	return nil
+}
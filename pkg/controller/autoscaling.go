@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-logr/logr"
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// replicasHandoverFieldManager keeps spec.replicas of the Deployment when the controller stops
+// managing it, until the HorizontalPodAutoscaler takes over.
+const replicasHandoverFieldManager = "dash-controller-handover"
+
+// createUpdateHorizontalPodAutoscaler applies the HorizontalPodAutoscaler of the application and
+// deletes it when autoscaling is disabled.
+func (r *Reconciler) createUpdateHorizontalPodAutoscaler(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication) error {
+	if dashApp.Spec.Autoscaling == nil {
+		return r.deleteOwned(ctx, log, dashApp, &autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: dashApp.Name, Namespace: dashApp.Namespace}})
+	}
+	_, err := r.apply(ctx, log, dashApp, genHorizontalPodAutoscaler(dashApp))
+	return err
+}
+
+func genHorizontalPodAutoscaler(dashApp *dashv1alpha1.DashApplication) *autoscalingv2.HorizontalPodAutoscaler {
+	autoscaling := dashApp.Spec.Autoscaling
+
+	var metrics []autoscalingv2.MetricSpec
+	if autoscaling.TargetCPUUtilizationPercentage != nil {
+		metrics = append(metrics, utilizationMetric(corev1.ResourceCPU, *autoscaling.TargetCPUUtilizationPercentage))
+	}
+	if autoscaling.TargetMemoryUtilizationPercentage != nil {
+		metrics = append(metrics, utilizationMetric(corev1.ResourceMemory, *autoscaling.TargetMemoryUtilizationPercentage))
+	}
+	metrics = append(metrics, autoscaling.Metrics...)
+
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dashApp.Name,
+			Namespace: dashApp.Namespace,
+			Labels:    baseAppLabels(dashApp.Name, nil),
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: appsv1.SchemeGroupVersion.String(),
+				Kind:       "Deployment",
+				Name:       dashApp.Name,
+			},
+			MinReplicas: autoscaling.MinReplicas,
+			MaxReplicas: autoscaling.MaxReplicas,
+			Metrics:     metrics,
+			Behavior:    autoscaling.Behavior,
+		},
+	}
+}
+
+func utilizationMetric(resourceName corev1.ResourceName, utilization int32) autoscalingv2.MetricSpec {
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name: resourceName,
+			Target: autoscalingv2.MetricTarget{
+				Type:               autoscalingv2.UtilizationMetricType,
+				AverageUtilization: &utilization,
+			},
+		},
+	}
+}
+
+// handOverReplicas releases the ownership of the Deployment replicas before the controller stops
+// applying them. Removing a field only owned by the controller from the applied configuration
+// would reset the replicas to the default of 1, so the current value is first co-owned by a
+// separate field manager, as recommended for transferring ownership to an autoscaler.
+func (r *Reconciler) handOverReplicas(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication) error {
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(dashApp), deployment); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	// Replicas scaled to zero by the controller are released without handover, the default of
+	// 1 replica lets the autoscaler, which ignores targets with zero replicas, take over again.
+	if !metav1.IsControlledBy(deployment, dashApp) || deployment.Spec.Replicas == nil || *deployment.Spec.Replicas == 0 || !managesReplicas(deployment) {
+		return nil
+	}
+
+	handover := &unstructured.Unstructured{}
+	handover.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("Deployment"))
+	handover.SetName(deployment.Name)
+	handover.SetNamespace(deployment.Namespace)
+	if err := unstructured.SetNestedField(handover.Object, int64(*deployment.Spec.Replicas), "spec", "replicas"); err != nil {
+		return err
+	}
+	log.Info("hand over deployment replicas", "replicas", *deployment.Spec.Replicas)
+	return r.Patch(ctx, handover, client.Apply, client.FieldOwner(replicasHandoverFieldManager))
+}
+
+// managesReplicas reports whether the controller field manager applied spec.replicas.
+func managesReplicas(deployment *appsv1.Deployment) bool {
+	for _, entry := range deployment.ManagedFields {
+		if entry.Manager != FieldManager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+		fields := map[string]map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, ok := fields["f:spec"]["f:replicas"]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+// reconcileReplicas applies the Deployment and HorizontalPodAutoscaler like a reconciliation
+// and returns the resulting Deployment.
+func reconcileReplicas(t *testing.T, r *Reconciler, dashApp *dashv1alpha1.DashApplication) *appsv1.Deployment {
+	t.Helper()
+	ctx := context.Background()
+	params := deploymentParams{image: dashApp.Spec.Container.Image, replicas: desiredReplicas(dashApp, nil, nil)}
+	if _, err := r.createUpdateDeployment(ctx, r.Log, dashApp, params); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.createUpdateHorizontalPodAutoscaler(ctx, r.Log, dashApp); err != nil {
+		t.Fatal(err)
+	}
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(dashApp), deployment); err != nil {
+		t.Fatal(err)
+	}
+	return deployment
+}
+
+// scale sets the replicas like the HorizontalPodAutoscaler does through the scale subresource.
+func scale(t *testing.T, r *Reconciler, deployment *appsv1.Deployment, replicas int32) {
+	t.Helper()
+	patch := client.MergeFrom(deployment.DeepCopy())
+	deployment.Spec.Replicas = &replicas
+	if err := r.Patch(context.Background(), deployment, patch); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertReplicas(t *testing.T, deployment *appsv1.Deployment, want int32, wantManaged bool) {
+	t.Helper()
+	if deployment.Spec.Replicas == nil {
+		t.Fatalf("replicas = nil, want %d", want)
+	}
+	if got := *deployment.Spec.Replicas; got != want {
+		t.Errorf("replicas = %d, want %d", got, want)
+	}
+	if got := managesReplicas(deployment); got != wantManaged {
+		t.Errorf("managesReplicas = %t, want %t", got, wantManaged)
+	}
+}
+
+func testAutoscaling() *dashv1alpha1.Autoscaling {
+	return &dashv1alpha1.Autoscaling{MinReplicas: int32Ptr(1), MaxReplicas: 10}
+}
+
+func TestEnableAutoscaling(t *testing.T) {
+	dashApp := newTestApp("app")
+	dashApp.Spec.Replicas = int32Ptr(3)
+	r, _ := newTestReconciler(t, dashApp)
+
+	deployment := reconcileReplicas(t, r, dashApp)
+	assertReplicas(t, deployment, 3, true)
+
+	dashApp.Spec.Autoscaling = testAutoscaling()
+	deployment = reconcileReplicas(t, r, dashApp)
+	assertReplicas(t, deployment, 3, false)
+
+	// the controller no longer fights with the autoscaler
+	scale(t, r, deployment, 5)
+	deployment = reconcileReplicas(t, r, dashApp)
+	assertReplicas(t, deployment, 5, false)
+}
+
+func TestResumeWithAutoscaling(t *testing.T) {
+	dashApp := newTestApp("app")
+	dashApp.Spec.Autoscaling = testAutoscaling()
+	r, _ := newTestReconciler(t, dashApp)
+
+	deployment := reconcileReplicas(t, r, dashApp)
+	scale(t, r, deployment, 4)
+
+	dashApp.Spec.Suspend = true
+	dashApp.Status.SuspendedReplicas = int32Ptr(4)
+	deployment = reconcileReplicas(t, r, dashApp)
+	assertReplicas(t, deployment, 0, true)
+
+	// the replicas of the autoscaler are restored first, then handed over again
+	dashApp.Spec.Suspend = false
+	deployment = reconcileReplicas(t, r, dashApp)
+	assertReplicas(t, deployment, 4, true)
+
+	dashApp.Status.SuspendedReplicas = nil
+	deployment = reconcileReplicas(t, r, dashApp)
+	assertReplicas(t, deployment, 4, false)
+	scale(t, r, deployment, 6)
+	deployment = reconcileReplicas(t, r, dashApp)
+	assertReplicas(t, deployment, 6, false)
+}
+
+func TestDisableAutoscaling(t *testing.T) {
+	dashApp := newTestApp("app")
+	dashApp.Spec.Replicas = int32Ptr(2)
+	dashApp.Spec.Autoscaling = testAutoscaling()
+	r, _ := newTestReconciler(t, dashApp)
+
+	deployment := reconcileReplicas(t, r, dashApp)
+	scale(t, r, deployment, 5)
+
+	dashApp.Spec.Autoscaling = nil
+	deployment = reconcileReplicas(t, r, dashApp)
+	assertReplicas(t, deployment, 2, true)
+
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(dashApp), hpa); !apierrors.IsNotFound(err) {
+		t.Errorf("get HorizontalPodAutoscaler: %v, want not found", err)
+	}
+}
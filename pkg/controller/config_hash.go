@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// ConfigHashAnnotation is set on the pod template to roll the deployment
+	// whenever a referenced Secret or ConfigMap changes.
+	ConfigHashAnnotation = "dash.plural.sh/config-hash"
+
+	secretRefsIndex    = ".spec.secretRefs"
+	configMapRefsIndex = ".spec.configMapRefs"
+)
+
+// referencedSecrets returns the sorted names of the Secrets used by the application container,
+// including the git credentials of applications run from git and the Flask secret key.
+func referencedSecrets(dashApp *dashv1alpha1.DashApplication) []string {
+	names := map[string]struct{}{}
+	for _, env := range dashApp.Spec.Container.Env {
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+			names[env.ValueFrom.SecretKeyRef.Name] = struct{}{}
+		}
+	}
+	for _, envFrom := range dashApp.Spec.Container.EnvFrom {
+		if envFrom.SecretRef != nil {
+			names[envFrom.SecretRef.Name] = struct{}{}
+		}
+	}
+	if source := dashApp.Spec.Source; source != nil && source.Git != nil && source.Git.CredentialsSecretRef != nil {
+		names[source.Git.CredentialsSecretRef.Name] = struct{}{}
+	}
+	if dash := dashApp.Spec.Dash; dash != nil && dash.SecretKeyRef != nil {
+		names[dash.SecretKeyRef.Name] = struct{}{}
+	}
+	return sortedKeys(names)
+}
+
+// referencedConfigMaps returns the sorted names of the ConfigMaps used by the application container,
+// including the source of applications run from source.
+func referencedConfigMaps(dashApp *dashv1alpha1.DashApplication) []string {
+	names := map[string]struct{}{}
+	for _, env := range dashApp.Spec.Container.Env {
+		if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+			names[env.ValueFrom.ConfigMapKeyRef.Name] = struct{}{}
+		}
+	}
+	for _, envFrom := range dashApp.Spec.Container.EnvFrom {
+		if envFrom.ConfigMapRef != nil {
+			names[envFrom.ConfigMapRef.Name] = struct{}{}
+		}
+	}
+	if name := sourceConfigMapName(dashApp); name != "" {
+		names[name] = struct{}{}
+	}
+	return sortedKeys(names)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// configHash computes a hash over the content of all Secrets and ConfigMaps referenced
+// by the application. Missing references are skipped, so creating them later changes
+// the hash and rolls the deployment. An empty string is returned when nothing is referenced.
+func (r *Reconciler) configHash(ctx context.Context, dashApp *dashv1alpha1.DashApplication) (string, error) {
+	secrets := referencedSecrets(dashApp)
+	configMaps := referencedConfigMaps(dashApp)
+	if len(secrets) == 0 && len(configMaps) == 0 {
+		return "", nil
+	}
+
+	hash := sha256.New()
+	write := func(parts ...string) {
+		for _, part := range parts {
+			hash.Write([]byte(part))
+			hash.Write([]byte{0})
+		}
+	}
+
+	for _, name := range secrets {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: dashApp.Namespace, Name: name}, secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+		write("secret", name)
+		for _, key := range sortedKeys(secret.Data) {
+			write(key, string(secret.Data[key]))
+		}
+	}
+
+	for _, name := range configMaps {
+		configMap := &corev1.ConfigMap{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: dashApp.Namespace, Name: name}, configMap); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+		write("configmap", name)
+		for _, key := range sortedKeys(configMap.Data) {
+			write(key, configMap.Data[key])
+		}
+		for _, key := range sortedKeys(configMap.BinaryData) {
+			write(key, string(configMap.BinaryData[key]))
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// requestsForReferencingApps returns a map function that enqueues all DashApplications in the
+// object namespace which reference the object through the given field index.
+func (r *Reconciler) requestsForReferencingApps(index string) func(client.Object) []reconcile.Request {
+	return func(obj client.Object) []reconcile.Request {
+		dashApps := &dashv1alpha1.DashApplicationList{}
+		if err := r.List(context.Background(), dashApps, client.InNamespace(obj.GetNamespace()), client.MatchingFields{index: obj.GetName()}); err != nil {
+			r.Log.Error(err, "failed to list referencing applications", "index", index, "object", client.ObjectKeyFromObject(obj))
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(dashApps.Items))
+		for _, dashApp := range dashApps.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&dashApp)})
+		}
+		return requests
+	}
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// secretKeyKey is the key of the generated Flask secret key.
+	secretKeyKey = "secret-key"
+	// secretKeyBytes is the length of the generated secret key before hex encoding.
+	secretKeyBytes = 32
+)
+
+// dashEnvVars returns the Dash configuration environment variables. The routes prefix follows
+// the ingress path, the requests prefix defaults to it, so both stay consistent.
+func dashEnvVars(dashApp *dashv1alpha1.DashApplication) []corev1.EnvVar {
+	var envVars []corev1.EnvVar
+	routesPrefix := ""
+	if dashApp.Spec.Ingress != nil && dashApp.Spec.Ingress.Path != "" && dashApp.Spec.Ingress.Path != "/" {
+		routesPrefix = fmt.Sprintf("%s/", dashApp.Spec.Ingress.Path)
+		envVars = append(envVars, corev1.EnvVar{Name: "DASH_ROUTES_PATHNAME_PREFIX", Value: routesPrefix})
+	}
+
+	config := dashApp.Spec.Dash
+	if config == nil {
+		return envVars
+	}
+
+	requestsPrefix := routesPrefix
+	if config.RequestsPathnamePrefix != "" {
+		requestsPrefix = config.RequestsPathnamePrefix
+	}
+	if requestsPrefix != "" {
+		envVars = append(envVars, corev1.EnvVar{Name: "DASH_REQUESTS_PATHNAME_PREFIX", Value: requestsPrefix})
+	}
+	for _, flag := range []struct {
+		name  string
+		value *bool
+	}{
+		{"DASH_DEBUG", config.Debug},
+		{"DASH_SERVE_LOCALLY", config.ServeLocally},
+		{"DASH_COMPRESS", config.Compress},
+	} {
+		if flag.value != nil {
+			envVars = append(envVars, corev1.EnvVar{Name: flag.name, Value: strconv.FormatBool(*flag.value)})
+		}
+	}
+	if config.AssetsURLPath != "" {
+		envVars = append(envVars, corev1.EnvVar{Name: "DASH_ASSETS_URL_PATH", Value: config.AssetsURLPath})
+	}
+	if config.AssetsExternalPath != "" {
+		envVars = append(envVars, corev1.EnvVar{Name: "DASH_ASSETS_EXTERNAL_PATH", Value: config.AssetsExternalPath})
+	}
+
+	return append(envVars, corev1.EnvVar{
+		Name: "SECRET_KEY",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: secretKeySelector(dashApp),
+		},
+	})
+}
+
+// secretKeySelector returns the Secret key holding the Flask secret key, the generated one
+// unless the application references its own.
+func secretKeySelector(dashApp *dashv1alpha1.DashApplication) *corev1.SecretKeySelector {
+	if dashApp.Spec.Dash.SecretKeyRef != nil {
+		return dashApp.Spec.Dash.SecretKeyRef
+	}
+	return &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: secretKeySecretName(dashApp)},
+		Key:                  secretKeyKey,
+	}
+}
+
+func secretKeySecretName(dashApp *dashv1alpha1.DashApplication) string {
+	return dashApp.Name + "-secret-key"
+}
+
+// createSecretKeySecret generates the Flask secret key of applications with a dash block. The
+// key is only generated once, so sessions survive restarts and are shared by all replicas. It
+// is deleted when the application references its own key or removes the dash block.
+func (r *Reconciler) createSecretKeySecret(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication) error {
+	key := client.ObjectKey{Namespace: dashApp.Namespace, Name: secretKeySecretName(dashApp)}
+	if dashApp.Spec.Dash == nil || dashApp.Spec.Dash.SecretKeyRef != nil {
+		return r.deleteOwned(ctx, log, dashApp, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}})
+	}
+
+	if err := r.Get(ctx, key, &corev1.Secret{}); err == nil || !apierrors.IsNotFound(err) {
+		return err
+	}
+	secretKey := make([]byte, secretKeyBytes)
+	if _, err := rand.Read(secretKey); err != nil {
+		return err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+			Labels:    baseAppLabels(dashApp.Name, nil),
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			secretKeyKey: hex.EncodeToString(secretKey),
+		},
+	}
+	_, err := r.apply(ctx, log, dashApp, secret)
+	return err
+}
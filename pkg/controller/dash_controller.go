@@ -2,33 +2,58 @@ package controller
 
 import (
 	"context"
-	"fmt"
-	"reflect"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	"github.com/pluralsh/dash-controller/pkg/activator"
+	"github.com/pluralsh/dash-controller/pkg/config"
 	"github.com/pluralsh/dash-controller/pkg/kubernetes"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// Finalizers set by previous controller versions. Owned resources are now cleaned up by the
+// garbage collector through owner references, the finalizers are only removed from existing objects.
 const (
 	IngressFinalizer    = "pluralsh.dash-controller/ingress-protection"
 	DeploymentFinalizer = "pluralsh.dash-controller/deployment-protection"
 	ServiceFinalizer    = "pluralsh.dash-controller/service-protection"
 )
 
-// Reconciler reconciles a DatabaseRequest object
+// httpPortName names the application container port. The DashApplication name can't be used,
+// port names are limited to 15 characters.
+const httpPortName = "http"
+
+// FieldManager is the field manager used to server-side apply the generated resources.
+const FieldManager = "dash-controller"
+
+// Reconciler reconciles a DashApplication object
 type Reconciler struct {
 	client.Client
-	Log logr.Logger
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Config   *config.Config
+	// Activator serves applications with scale-to-zero enabled, it is nil when scale-to-zero
+	// is disabled. ActivatorAddress is the pod IP the application Services are pointed at.
+	Activator        *activator.Activator
+	ActivatorAddress string
 }
 
 func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -37,6 +62,9 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	dashApp := &dashv1alpha1.DashApplication{}
 	if err := r.Get(ctx, req.NamespacedName, dashApp); err != nil {
 		if apierrors.IsNotFound(err) {
+			if r.Activator != nil {
+				r.Activator.Unregister(req.NamespacedName)
+			}
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, err
@@ -46,322 +74,340 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	namespace := dashApp.Namespace
 
 	if !dashApp.GetDeletionTimestamp().IsZero() {
-		if controllerutil.ContainsFinalizer(dashApp, IngressFinalizer) {
-			log.Info("delete ingress")
-			if err := r.Delete(ctx, &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}); err != nil {
-				if !apierrors.IsNotFound(err) {
-					return ctrl.Result{}, err
-				}
-			}
-			kubernetes.TryRemoveFinalizer(ctx, r.Client, dashApp, IngressFinalizer)
+		if r.Activator != nil {
+			r.Activator.Unregister(req.NamespacedName)
 		}
-		if controllerutil.ContainsFinalizer(dashApp, ServiceFinalizer) {
-			log.Info("delete service")
-			if err := r.Delete(ctx, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}); err != nil {
-				if !apierrors.IsNotFound(err) {
-					return ctrl.Result{}, err
-				}
-			}
-			kubernetes.TryRemoveFinalizer(ctx, r.Client, dashApp, ServiceFinalizer)
+		// Applications created by previous controller versions may still own resources
+		// without an owner reference, delete them explicitly before releasing the finalizers.
+		legacyResources := []struct {
+			finalizer string
+			obj       client.Object
+		}{
+			{IngressFinalizer, &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}},
+			{ServiceFinalizer, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}},
+			{DeploymentFinalizer, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}},
 		}
-		if controllerutil.ContainsFinalizer(dashApp, DeploymentFinalizer) {
-			log.Info("delete deployment")
-			if err := r.Delete(ctx, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}); err != nil {
+		for _, legacy := range legacyResources {
+			if !controllerutil.ContainsFinalizer(dashApp, legacy.finalizer) {
+				continue
+			}
+			kind := r.kindOf(legacy.obj)
+			log.Info("delete " + kind)
+			if err := r.Delete(ctx, legacy.obj); err != nil {
 				if !apierrors.IsNotFound(err) {
+					r.Recorder.Eventf(dashApp, corev1.EventTypeWarning, "FailedDelete", "Failed to delete %s %s: %v", kind, name, err)
 					return ctrl.Result{}, err
 				}
+			} else {
+				r.Recorder.Eventf(dashApp, corev1.EventTypeNormal, "Deleted", "Deleted %s %s", kind, name)
+			}
+			if err := kubernetes.TryRemoveFinalizer(ctx, r.Client, dashApp, legacy.finalizer); err != nil {
+				r.Recorder.Eventf(dashApp, corev1.EventTypeWarning, "FailedRemoveFinalizer", "Failed to remove finalizer %s: %v", legacy.finalizer, err)
+				return ctrl.Result{}, err
 			}
-			kubernetes.TryRemoveFinalizer(ctx, r.Client, dashApp, DeploymentFinalizer)
 		}
 		return ctrl.Result{}, nil
 	}
 
-	if err := r.createUpdateDeployment(ctx, log, dashApp); err != nil {
+	// The generated source is part of the configuration hash, it is applied first so the pods
+	// start with the current source.
+	if err := r.createUpdateSourceConfigMap(ctx, log, dashApp); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	if err := r.createUpdateService(ctx, log, dashApp); err != nil {
+	if err := r.createSecretKeySecret(ctx, log, dashApp); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	if err := r.createUpdateIngress(ctx, log, dashApp); err != nil {
+	configHash, err := r.configHash(ctx, dashApp)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	dashApp.Status.Ready = true
-	if err := r.Status().Update(ctx, dashApp); err != nil {
+	resources, err := r.containerResources(dashApp)
+	if err != nil {
+		log.Info("invalid resource profile", "reason", err.Error())
+		r.Recorder.Event(dashApp, corev1.EventTypeWarning, "InvalidResourceProfile", err.Error())
+		return ctrl.Result{}, r.updateInvalidSpecStatus(ctx, dashApp, "InvalidResourceProfile", err.Error())
+	}
+
+	now := time.Now()
+	schedule, scheduleRequeueAfter, err := r.reconcileSchedule(log, dashApp, now)
+	if err != nil {
+		log.Info("invalid schedule", "reason", err.Error())
+		r.Recorder.Event(dashApp, corev1.EventTypeWarning, "InvalidSchedule", err.Error())
+		return ctrl.Result{}, r.updateInvalidSpecStatus(ctx, dashApp, "InvalidSchedule", err.Error())
+	}
+
+	idle, idleRequeueAfter, err := r.reconcileIdle(log, dashApp, schedule, now)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
-}
+	suspendedReplicas, err := r.suspendedReplicas(ctx, dashApp)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 
-// Generate the desired Service object for the workspace
-func generateService(dashApp *dashv1alpha1.DashApplication) *corev1.Service {
-	svc := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        dashApp.Name,
-			Namespace:   dashApp.Namespace,
-			Labels:      baseAppLabels(dashApp.Name, nil),
-			Annotations: dashApp.Spec.ServiceAnnotations,
-		},
-		Spec: corev1.ServiceSpec{
-			Selector: baseAppLabels(dashApp.Name, nil),
-			Ports: []corev1.ServicePort{{
-				Protocol:   corev1.ProtocolTCP,
-				Port:       80,
-				TargetPort: intstr.FromString(dashApp.Name),
-			}},
-		},
-	}
-
-	if dashApp.Spec.Ingress == nil {
-		svc.Spec.Type = "LoadBalancer"
-	}
-
-	return svc
-}
+	if err := r.createUpdatePersistentVolumeClaim(ctx, log, dashApp); err != nil {
+		return ctrl.Result{}, err
+	}
+	strategy, err := r.deploymentStrategy(ctx, dashApp)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 
-func genIngress(dashApp *dashv1alpha1.DashApplication) *networkingv1.Ingress {
-	prefix := networkingv1.PathTypePrefix
-	path := "/"
-	if dashApp.Spec.Ingress.Path != "" {
-		path = dashApp.Spec.Ingress.Path
-	}
-	ingress := &networkingv1.Ingress{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        dashApp.Name,
-			Namespace:   dashApp.Namespace,
-			Labels:      baseAppLabels(dashApp.Name, nil),
-			Annotations: dashApp.Spec.Ingress.Annotations,
-		},
-		Spec: networkingv1.IngressSpec{
-			IngressClassName: dashApp.Spec.Ingress.IngressClassName,
-			Rules: []networkingv1.IngressRule{
-				{
-					Host: dashApp.Spec.Ingress.Host,
-					IngressRuleValue: networkingv1.IngressRuleValue{
-						HTTP: &networkingv1.HTTPIngressRuleValue{
-							Paths: []networkingv1.HTTPIngressPath{
-								{
-									Path:     path,
-									PathType: &prefix,
-									Backend: networkingv1.IngressBackend{
-										Service: &networkingv1.IngressServiceBackend{
-											Name: dashApp.Name,
-											Port: networkingv1.ServiceBackendPort{
-												Number: 80,
-											},
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-	if dashApp.Spec.Ingress.TLS != nil {
-		ingress.Spec.TLS = []networkingv1.IngressTLS{
-			{
-				Hosts:      []string{dashApp.Spec.Ingress.TLS.Host},
-				SecretName: dashApp.Spec.Ingress.TLS.SecretName,
-			},
-		}
+	params := deploymentParams{
+		image:                     r.applicationImage(dashApp),
+		gitSyncImage:              r.Config.Source.GitSyncImage,
+		configHash:                configHash,
+		resources:                 resources,
+		replicas:                  desiredReplicas(dashApp, schedule, idle),
+		topologySpreadConstraints: r.topologySpreadConstraints(dashApp),
+		strategy:                  strategy,
+	}
+	deployment, err := r.createUpdateDeployment(ctx, log, dashApp, params)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
-	return ingress
-}
+	if err := r.createUpdateHorizontalPodAutoscaler(ctx, log, dashApp); err != nil {
+		return ctrl.Result{}, err
+	}
 
-func genDeployment(dashApp *dashv1alpha1.DashApplication) *appsv1.Deployment {
-	name := dashApp.Name
-	var envVars []corev1.EnvVar
-
-	if dashApp.Spec.Ingress != nil && dashApp.Spec.Ingress.Path != "" && dashApp.Spec.Ingress.Path != "/" {
-		envVars = []corev1.EnvVar{
-			{
-				Name:  "DASH_ROUTES_PATHNAME_PREFIX",
-				Value: fmt.Sprintf("%s/", dashApp.Spec.Ingress.Path),
-			},
-		}
+	// The replicas of the applied Deployment include those set by the HorizontalPodAutoscaler.
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	if err := r.createUpdatePodDisruptionBudget(ctx, log, dashApp, replicas); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	deployment := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: dashApp.Namespace,
-			Labels:    baseAppLabels(name, dashApp.Spec.Labels),
-		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: dashApp.Spec.Replicas,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: baseAppLabels(name, nil),
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: baseAppLabels(name, dashApp.Spec.Labels),
-				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:            name,
-							ImagePullPolicy: corev1.PullAlways,
-							Image:           dashApp.Spec.Container.Image,
-							Args:            dashApp.Spec.Container.Args,
-							Command:         dashApp.Spec.Container.Command,
-							Ports: []corev1.ContainerPort{
-								{
-									ContainerPort: dashApp.Spec.Container.ContainerPort,
-									Protocol:      corev1.ProtocolTCP,
-									Name:          name,
-								},
-							},
-							Env: envVars,
-						},
-					},
-				},
-			},
-		},
-	}
-
-	return deployment
-}
+	activatorPort := int32(0)
+	switch {
+	case idle != nil:
+		activatorPort = idle.ActivatorPort
+	case r.pausedPageEnabled(dashApp):
+		activatorPort = r.Activator.PausedPagePort()
+	}
+	if err := r.createUpdateService(ctx, log, dashApp, activatorPort); err != nil {
+		return ctrl.Result{}, err
+	}
 
-func baseAppLabels(name string, additionalLabels map[string]string) map[string]string {
-	labels := map[string]string{
-		"dash.plural.sh/name": name,
+	conflict, err := r.routeConflict(ctx, dashApp)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
-	for k, v := range additionalLabels {
-		labels[k] = v
+
+	if err := r.createUpdateIngress(ctx, log, dashApp, conflict); err != nil {
+		return ctrl.Result{}, err
 	}
-	return labels
-}
 
-func (r *Reconciler) createUpdateIngress(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication) error {
-	if dashApp.Spec.Ingress != nil {
-		update := false
-		name := dashApp.Name
-		namespace := dashApp.Namespace
-		newIngress := genIngress(dashApp)
-		ingress := &networkingv1.Ingress{}
-		if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, ingress); err != nil {
-			if !apierrors.IsNotFound(err) {
-				return err
-			}
+	// All owned resources carry an owner reference now, the garbage collector takes care of them.
+	if err := kubernetes.TryRemoveFinalizer(ctx, r.Client, dashApp, IngressFinalizer, ServiceFinalizer, DeploymentFinalizer); err != nil {
+		r.Recorder.Eventf(dashApp, corev1.EventTypeWarning, "FailedRemoveFinalizer", "Failed to remove legacy finalizers: %v", err)
+		return ctrl.Result{}, err
+	}
 
-			log.Info("create ingress")
-			if err := r.Create(ctx, newIngress); err != nil {
-				return err
-			}
-			if err := kubernetes.TryAddFinalizer(ctx, r.Client, dashApp, IngressFinalizer); err != nil {
-				return err
-			}
-			return nil
-		}
-		if !reflect.DeepEqual(ingress.Annotations, newIngress.Annotations) {
-			update = true
-			ingress.Annotations = newIngress.Annotations
-		}
-		if !reflect.DeepEqual(ingress.Spec.IngressClassName, newIngress.Spec.IngressClassName) {
-			update = true
-			ingress.Spec.IngressClassName = newIngress.Spec.IngressClassName
-		}
-		if !reflect.DeepEqual(ingress.Spec.Rules[0].Host, newIngress.Spec.Rules[0].Host) {
-			update = true
-			ingress.Spec.Rules[0].Host = newIngress.Spec.Rules[0].Host
-		}
-		if !reflect.DeepEqual(ingress.Spec.Rules[0].HTTP.Paths, newIngress.Spec.Rules[0].HTTP.Paths) {
-			update = true
-			ingress.Spec.Rules[0].HTTP.Paths = newIngress.Spec.Rules[0].HTTP.Paths
-		}
+	if conflict != nil && !hasRouteConflict(dashApp) {
+		r.Recorder.Eventf(dashApp, corev1.EventTypeWarning, "RouteConflict", "Ingress route overlaps with the older application %s", client.ObjectKeyFromObject(conflict))
+	}
+	setRouteConflictCondition(dashApp, conflict)
+	r.setSuspendedCondition(dashApp, suspendedReplicas)
+	dashApp.Status.Schedule = schedule
+	if dashApp.Status.Source, err = r.reconcileSourceStatus(ctx, dashApp); err != nil {
+		return ctrl.Result{}, err
+	}
 
-		if update {
-			log.Info("update ingress")
-			return r.Update(ctx, ingress)
-		}
+	if err := r.updateStatus(ctx, dashApp, configHash, idle); err != nil {
+		return ctrl.Result{}, err
 	}
-	return nil
+
+	result := ctrl.Result{RequeueAfter: minRequeueAfter(idleRequeueAfter, scheduleRequeueAfter)}
+	if source := dashApp.Spec.Source; source != nil && source.Git != nil {
+		// the pods report new commits, they are collected periodically
+		result.RequeueAfter = minRequeueAfter(result.RequeueAfter, gitPeriod(source.Git))
+	}
+	if !dashApp.Status.Ready && !dashApp.Spec.Suspend && !scheduledDown(schedule) {
+		log.Info("application not ready", "reason", dashApp.Status.Reason)
+		result.RequeueAfter = minRequeueAfter(result.RequeueAfter, notReadyRequeueAfter)
+	}
+
+	return result, nil
 }
 
-func (r *Reconciler) createUpdateService(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication) error {
-	name := dashApp.Name
-	namespace := dashApp.Namespace
-	newService := generateService(dashApp)
-	svc := &corev1.Service{}
-	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, svc); err != nil {
+// minRequeueAfter returns the shorter of two requeue periods, zero means no requeue.
+func minRequeueAfter(a, b time.Duration) time.Duration {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// apply creates or updates the generated object with server-side apply. The object is owned
+// by the DashApplication and the controller takes ownership of every field set in it, fields
+// managed by others, e.g. injected sidecars, are left untouched. On success obj holds the
+// current state of the resource.
+func (r *Reconciler) apply(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication, obj client.Object) (controllerutil.OperationResult, error) {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	if err := controllerutil.SetControllerReference(dashApp, obj, r.Scheme); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	kind := strings.ToLower(gvk.Kind)
+	name := obj.GetName()
+	existing, err := r.Scheme.New(gvk)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	current := existing.(client.Object)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), current); err != nil {
 		if !apierrors.IsNotFound(err) {
-			return err
-		}
-		log.Info("create service")
-		if err := r.Create(ctx, newService); err != nil {
-			return err
+			return controllerutil.OperationResultNone, err
 		}
-		if err := kubernetes.TryAddFinalizer(ctx, r.Client, dashApp, ServiceFinalizer); err != nil {
-			return err
-		}
-		return nil
+		current = nil
 	}
 
-	if !reflect.DeepEqual(newService.Annotations, svc.Annotations) {
-		svc.Annotations = newService.Annotations
-		log.Info("update service")
-		return r.Update(ctx, svc)
+	if err := r.Patch(ctx, obj, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership); err != nil {
+		if current == nil {
+			r.Recorder.Eventf(dashApp, corev1.EventTypeWarning, "FailedCreate", "Failed to create %s %s: %v", kind, name, err)
+		} else {
+			r.Recorder.Eventf(dashApp, corev1.EventTypeWarning, "FailedUpdate", "Failed to update %s %s: %v", kind, name, err)
+		}
+		return controllerutil.OperationResultNone, err
 	}
 
-	return nil
+	if current == nil {
+		log.Info("create " + kind)
+		r.Recorder.Eventf(dashApp, corev1.EventTypeNormal, "Created", "Created %s %s", kind, name)
+		return controllerutil.OperationResultCreated, nil
+	}
+	if err := r.dropLegacyFieldManager(ctx, obj); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	if current.GetResourceVersion() != obj.GetResourceVersion() {
+		log.Info("update " + kind)
+		r.Recorder.Eventf(dashApp, corev1.EventTypeNormal, "Updated", "Updated %s %s", kind, name)
+		return controllerutil.OperationResultUpdated, nil
+	}
+	return controllerutil.OperationResultNone, nil
 }
 
-func (r *Reconciler) createUpdateDeployment(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication) error {
-	var update bool
-	name := dashApp.Name
-	namespace := dashApp.Namespace
-	newDeployment := genDeployment(dashApp)
-	deployment := &appsv1.Deployment{}
-	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, deployment); err != nil {
-		if !apierrors.IsNotFound(err) {
-			return err
-		}
-		log.Info("create deployment")
-		if err := r.Create(ctx, newDeployment); err != nil {
-			return err
-		}
-		if err := kubernetes.TryAddFinalizer(ctx, r.Client, dashApp, DeploymentFinalizer); err != nil {
-			return err
-		}
+// deleteOwned deletes a generated object which is no longer desired. Objects which are not
+// controlled by the DashApplication are left alone.
+func (r *Reconciler) deleteOwned(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication, obj client.Object) error {
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(obj, dashApp) {
 		return nil
 	}
 
-	if !reflect.DeepEqual(newDeployment.Spec.Replicas, deployment.Spec.Replicas) {
-		deployment.Spec.Replicas = newDeployment.Spec.Replicas
-		update = true
+	kind := r.kindOf(obj)
+	uid := obj.GetUID()
+	log.Info("delete " + kind)
+	if err := r.Delete(ctx, obj, client.Preconditions{UID: &uid}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		r.Recorder.Eventf(dashApp, corev1.EventTypeWarning, "FailedDelete", "Failed to delete %s %s: %v", kind, obj.GetName(), err)
+		return err
 	}
-	if !reflect.DeepEqual(newDeployment.Spec.Template.Spec.Containers[0].Ports, deployment.Spec.Template.Spec.Containers[0].Ports) {
-		deployment.Spec.Template.Spec.Containers[0].Ports = newDeployment.Spec.Template.Spec.Containers[0].Ports
-		update = true
+	r.Recorder.Eventf(dashApp, corev1.EventTypeNormal, "Deleted", "Deleted %s %s", kind, obj.GetName())
+	return nil
+}
+
+// kindOf returns the lower case kind of the object for logs and events.
+func (r *Reconciler) kindOf(obj client.Object) string {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return "object"
 	}
-	if !reflect.DeepEqual(newDeployment.Spec.Template.Spec.Containers[0].Image, deployment.Spec.Template.Spec.Containers[0].Image) {
-		deployment.Spec.Template.Spec.Containers[0].Image = newDeployment.Spec.Template.Spec.Containers[0].Image
-		update = true
+	return strings.ToLower(gvk.Kind)
+}
+
+// dropLegacyFieldManager removes the managed fields entry created when previous controller
+// versions updated the object with plain updates. As long as that entry exists, fields removed
+// from the generated object would never be pruned by server-side apply.
+func (r *Reconciler) dropLegacyFieldManager(ctx context.Context, obj client.Object) error {
+	managedFields := obj.GetManagedFields()
+	filtered := make([]metav1.ManagedFieldsEntry, 0, len(managedFields))
+	for _, entry := range managedFields {
+		if entry.Manager == FieldManager && entry.Operation == metav1.ManagedFieldsOperationUpdate && entry.Subresource == "" {
+			continue
+		}
+		filtered = append(filtered, entry)
 	}
-	if !reflect.DeepEqual(newDeployment.Spec.Template.Spec.Containers[0].Command, deployment.Spec.Template.Spec.Containers[0].Command) {
-		deployment.Spec.Template.Spec.Containers[0].Command = newDeployment.Spec.Template.Spec.Containers[0].Command
-		update = true
+	if len(filtered) == len(managedFields) {
+		return nil
 	}
-	if !reflect.DeepEqual(newDeployment.Spec.Template.Spec.Containers[0].Args, deployment.Spec.Template.Spec.Containers[0].Args) {
-		deployment.Spec.Template.Spec.Containers[0].Args = newDeployment.Spec.Template.Spec.Containers[0].Args
-		update = true
+
+	patch := client.MergeFromWithOptions(obj.DeepCopyObject().(client.Object), client.MergeFromWithOptimisticLock{})
+	obj.SetManagedFields(filtered)
+	return r.Patch(ctx, obj, patch)
+}
+
+func baseAppLabels(name string, additionalLabels map[string]string) map[string]string {
+	labels := map[string]string{
+		dashv1alpha1.NameLabel: name,
 	}
-	if update {
-		log.Info("update deployment")
-		return r.Update(ctx, deployment)
+	for k, v := range additionalLabels {
+		labels[k] = v
 	}
-	return nil
+	return labels
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// SetupWithManager sets up the controller with the Manager. Secrets are watched in all
+// namespaces, like the other resources, but only their metadata is cached. Reading them has to
+// bypass the cache, see the manager options in cmd/main.go.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	ctx := context.Background()
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &dashv1alpha1.DashApplication{}, secretRefsIndex, func(obj client.Object) []string {
+		return referencedSecrets(obj.(*dashv1alpha1.DashApplication))
+	}); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &dashv1alpha1.DashApplication{}, configMapRefsIndex, func(obj client.Object) []string {
+		return referencedConfigMaps(obj.(*dashv1alpha1.DashApplication))
+	}); err != nil {
+		return err
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &dashv1alpha1.DashApplication{}, routeHostIndex, func(obj client.Object) []string {
+		return routeHostKey(obj.(*dashv1alpha1.DashApplication))
+	}); err != nil {
+		return err
+	}
+
+	blder := ctrl.NewControllerManagedBy(mgr).
 		For(&dashv1alpha1.DashApplication{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &dashv1alpha1.DashApplication{}}, handler.EnqueueRequestsFromMapFunc(r.requestsForSharedHost)).
+		Owns(&appsv1.Deployment{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}, builder.OnlyMetadata).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&networkingv1.Ingress{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.requestsForReferencingApps(secretRefsIndex)), builder.OnlyMetadata).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.requestsForReferencingApps(configMapRefsIndex)))
+
+	if r.Activator != nil {
+		if err := mgr.Add(r.Activator); err != nil {
+			return err
+		}
+		if err := mgr.Add(&activatorFailover{reconciler: r}); err != nil {
+			return err
+		}
+		// requests for idle applications wake them up
+		blder = blder.Watches(&source.Channel{Source: r.Activator.Events()}, &handler.EnqueueRequestForObject{})
+	}
+
+	return blder.Complete(r)
 }
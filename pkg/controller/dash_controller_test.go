@@ -0,0 +1,329 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	"github.com/pluralsh/dash-controller/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+// newTestReconciler returns a reconciler backed by a fake client holding the given objects,
+// server-side apply is emulated by applyClient.
+func newTestReconciler(t *testing.T, objs ...client.Object) (*Reconciler, *record.FakeRecorder) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(dashv1alpha1.AddToScheme(scheme))
+
+	recorder := record.NewFakeRecorder(100)
+	return &Reconciler{
+		Client:   applyClient{fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()},
+		Log:      ctrl.Log.WithName("test"),
+		Scheme:   scheme,
+		Recorder: recorder,
+		Config:   config.Default(),
+	}, recorder
+}
+
+// newTestApp returns an application with the defaults of the webhook applied.
+func newTestApp(name string) *dashv1alpha1.DashApplication {
+	dashApp := &dashv1alpha1.DashApplication{}
+	dashApp.Name = name
+	dashApp.Namespace = "default"
+	dashApp.UID = types.UID(name + "-uid")
+	dashApp.Spec.Container.Image = "app:latest"
+	dashApp.Default()
+	return dashApp
+}
+
+// applyClient emulates server-side apply on top of the fake client, which doesn't support it.
+// Field ownership is tracked with structured-merge-diff like the API server does. The types
+// are deduced from the objects, so lists are atomic and no defaults are applied.
+type applyClient struct {
+	client.Client
+}
+
+// ignoredApplyFields are managed by the API server and never owned by field managers.
+var ignoredApplyFields = fieldpath.NewSet(
+	fieldpath.MakePathOrDie("apiVersion"),
+	fieldpath.MakePathOrDie("kind"),
+	fieldpath.MakePathOrDie("metadata", "name"),
+	fieldpath.MakePathOrDie("metadata", "namespace"),
+	fieldpath.MakePathOrDie("metadata", "uid"),
+	fieldpath.MakePathOrDie("metadata", "resourceVersion"),
+	fieldpath.MakePathOrDie("metadata", "generation"),
+	fieldpath.MakePathOrDie("metadata", "creationTimestamp"),
+	fieldpath.MakePathOrDie("metadata", "managedFields"),
+	fieldpath.MakePathOrDie("status"),
+)
+
+func (c applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	options := &client.PatchOptions{}
+	options.ApplyOptions(opts)
+	force := options.Force != nil && *options.Force
+
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	config, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	dropNulls(config)
+
+	key := client.ObjectKeyFromObject(obj)
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(gvk)
+	exists := true
+	if err := c.Get(ctx, key, live); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		exists = false
+	}
+	liveEntries := live.GetManagedFields()
+	managers := fieldpath.ManagedFields{}
+	for _, entry := range liveEntries {
+		set := &fieldpath.Set{}
+		if entry.FieldsV1 != nil {
+			if err := set.FromJSON(bytes.NewReader(entry.FieldsV1.Raw)); err != nil {
+				return err
+			}
+		}
+		managers[managerKey(entry.Manager, entry.Operation)] = fieldpath.NewVersionedSet(set, fieldpath.APIVersion(entry.APIVersion), entry.Operation == metav1.ManagedFieldsOperationApply)
+	}
+	live.SetManagedFields(nil)
+	dropNulls(live.Object)
+
+	liveValue, err := typed.DeducedParseableType.FromUnstructured(live.Object)
+	if err != nil {
+		return err
+	}
+	configValue, err := typed.DeducedParseableType.FromUnstructured(config)
+	if err != nil {
+		return err
+	}
+	version := fieldpath.APIVersion(gvk.GroupVersion().String())
+	updater := merge.Updater{
+		Converter:     sameVersionConverter{},
+		IgnoredFields: map[fieldpath.APIVersion]*fieldpath.Set{version: ignoredApplyFields},
+	}
+	merged, managers, err := updater.Apply(liveValue, configValue, version, managers, managerKey(options.FieldManager, metav1.ManagedFieldsOperationApply), force)
+	if err != nil {
+		return apierrors.NewConflict(schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind)}, key.Name, err)
+	}
+
+	entries, err := managedFieldsEntries(managers)
+	if err != nil {
+		return err
+	}
+	if exists && merged == nil && equality.Semantic.DeepEqual(entries, sortedEntries(liveEntries)) {
+		return c.Get(ctx, key, obj)
+	}
+	result := live
+	if merged != nil {
+		result = &unstructured.Unstructured{Object: merged.AsValue().Unstructured().(map[string]interface{})}
+	}
+	result.SetManagedFields(entries)
+	if exists {
+		err = c.Update(ctx, result)
+	} else {
+		err = c.Create(ctx, result)
+	}
+	if err != nil {
+		return err
+	}
+	return c.Get(ctx, key, obj)
+}
+
+func managerKey(manager string, operation metav1.ManagedFieldsOperationType) string {
+	return manager + "/" + string(operation)
+}
+
+// managedFieldsEntries encodes the field sets, sorted like sortedEntries.
+func managedFieldsEntries(managers fieldpath.ManagedFields) ([]metav1.ManagedFieldsEntry, error) {
+	var entries []metav1.ManagedFieldsEntry
+	for key, versionedSet := range managers {
+		raw, err := versionedSet.Set().ToJSON()
+		if err != nil {
+			return nil, err
+		}
+		manager, operation, _ := strings.Cut(key, "/")
+		entries = append(entries, metav1.ManagedFieldsEntry{
+			Manager:    manager,
+			Operation:  metav1.ManagedFieldsOperationType(operation),
+			APIVersion: string(versionedSet.APIVersion()),
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: raw},
+		})
+	}
+	return sortedEntries(entries), nil
+}
+
+func sortedEntries(entries []metav1.ManagedFieldsEntry) []metav1.ManagedFieldsEntry {
+	sorted := append([]metav1.ManagedFieldsEntry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return managerKey(sorted[i].Manager, sorted[i].Operation) < managerKey(sorted[j].Manager, sorted[j].Operation)
+	})
+	return sorted
+}
+
+// dropNulls removes null values, like creationTimestamp of typed objects, which the API server
+// doesn't store either.
+func dropNulls(value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if child == nil {
+				delete(value, key)
+				continue
+			}
+			dropNulls(child)
+		}
+	case []interface{}:
+		for _, child := range value {
+			dropNulls(child)
+		}
+	}
+}
+
+// sameVersionConverter is enough as all field managers use the version of the objects.
+type sameVersionConverter struct{}
+
+func (sameVersionConverter) Convert(object *typed.TypedValue, _ fieldpath.APIVersion) (*typed.TypedValue, error) {
+	return object, nil
+}
+
+func (sameVersionConverter) IsMissingVersionError(error) bool {
+	return false
+}
+
+// events drains the events recorded so far.
+func events(recorder *record.FakeRecorder) []string {
+	var recorded []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			recorded = append(recorded, event)
+		default:
+			return recorded
+		}
+	}
+}
+
+func testConfigMap(dashApp *dashv1alpha1.DashApplication, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: dashApp.Name, Namespace: dashApp.Namespace},
+		Data:       data,
+	}
+}
+
+func TestApplyEvents(t *testing.T) {
+	dashApp := newTestApp("app")
+	r, recorder := newTestReconciler(t, dashApp)
+	ctx := context.Background()
+
+	steps := []struct {
+		name   string
+		data   map[string]string
+		result controllerutil.OperationResult
+		events []string
+	}{
+		{"create", map[string]string{"a": "1"}, controllerutil.OperationResultCreated, []string{"Normal Created Created configmap app"}},
+		{"no-op", map[string]string{"a": "1"}, controllerutil.OperationResultNone, nil},
+		{"update", map[string]string{"a": "2"}, controllerutil.OperationResultUpdated, []string{"Normal Updated Updated configmap app"}},
+		{"no-op after update", map[string]string{"a": "2"}, controllerutil.OperationResultNone, nil},
+	}
+	for _, step := range steps {
+		result, err := r.apply(ctx, r.Log, dashApp, testConfigMap(dashApp, step.data))
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if result != step.result {
+			t.Errorf("%s: result = %s, want %s", step.name, result, step.result)
+		}
+		if got := events(recorder); !reflect.DeepEqual(got, step.events) {
+			t.Errorf("%s: events = %q, want %q", step.name, got, step.events)
+		}
+	}
+}
+
+func TestApplyDropsLegacyFieldManager(t *testing.T) {
+	dashApp := newTestApp("app")
+	// created by a previous controller version with a plain update
+	legacy := testConfigMap(dashApp, map[string]string{"a": "1", "b": "1"})
+	legacy.ManagedFields = []metav1.ManagedFieldsEntry{
+		{
+			Manager:    FieldManager,
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			APIVersion: "v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:data":{".":{},"f:a":{},"f:b":{}}}`)},
+		},
+		{
+			Manager:     "kubectl",
+			Operation:   metav1.ManagedFieldsOperationUpdate,
+			APIVersion:  "v1",
+			FieldsType:  "FieldsV1",
+			FieldsV1:    &metav1.FieldsV1{Raw: []byte(`{"f:status":{}}`)},
+			Subresource: "status",
+		},
+	}
+	r, _ := newTestReconciler(t, dashApp, legacy)
+	ctx := context.Background()
+
+	if _, err := r.apply(ctx, r.Log, dashApp, testConfigMap(dashApp, map[string]string{"a": "1", "b": "1"})); err != nil {
+		t.Fatal(err)
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(legacy), configMap); err != nil {
+		t.Fatal(err)
+	}
+	var managers []string
+	for _, entry := range configMap.ManagedFields {
+		managers = append(managers, entry.Manager+"/"+string(entry.Operation))
+	}
+	sort.Strings(managers)
+	if want := []string{FieldManager + "/Apply", "kubectl/Update"}; !reflect.DeepEqual(managers, want) {
+		t.Errorf("managers = %v, want %v", managers, want)
+	}
+
+	// without the legacy entry the removed key is no longer owned by anyone else and pruned
+	if _, err := r.apply(ctx, r.Log, dashApp, testConfigMap(dashApp, map[string]string{"a": "1"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(legacy), configMap); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"a": "1"}; !reflect.DeepEqual(configMap.Data, want) {
+		t.Errorf("data = %v, want %v", configMap.Data, want)
+	}
+}
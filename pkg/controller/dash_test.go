@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"testing"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+)
+
+func TestDashEnvVars(t *testing.T) {
+	enabled := true
+	tests := []struct {
+		name    string
+		ingress *dashv1alpha1.Ingress
+		dash    *dashv1alpha1.DashConfig
+		want    map[string]string
+	}{
+		{
+			name: "no ingress and no dash block",
+			want: map[string]string{},
+		},
+		{
+			name:    "routes prefix follows the ingress path",
+			ingress: &dashv1alpha1.Ingress{Path: "/app"},
+			want:    map[string]string{"DASH_ROUTES_PATHNAME_PREFIX": "/app/"},
+		},
+		{
+			name:    "dash block",
+			ingress: &dashv1alpha1.Ingress{Path: "/app"},
+			dash: &dashv1alpha1.DashConfig{
+				Compress:           &enabled,
+				AssetsURLPath:      "static",
+				AssetsExternalPath: "https://cdn.example.com/app/",
+			},
+			want: map[string]string{
+				"DASH_ROUTES_PATHNAME_PREFIX":   "/app/",
+				"DASH_REQUESTS_PATHNAME_PREFIX": "/app/",
+				"DASH_COMPRESS":                 "true",
+				"DASH_ASSETS_URL_PATH":          "static",
+				"DASH_ASSETS_EXTERNAL_PATH":     "https://cdn.example.com/app/",
+				"SECRET_KEY":                    "",
+			},
+		},
+		{
+			name: "unset assets paths",
+			dash: &dashv1alpha1.DashConfig{},
+			want: map[string]string{"SECRET_KEY": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dashApp := newTestApp("app")
+			dashApp.Spec.Ingress = tt.ingress
+			dashApp.Spec.Dash = tt.dash
+
+			got := map[string]string{}
+			for _, env := range dashEnvVars(dashApp) {
+				got[env.Name] = env.Value
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("dashEnvVars() = %v, want %v", got, tt.want)
+			}
+			for name, value := range tt.want {
+				if actual, ok := got[name]; !ok || actual != value {
+					t.Errorf("%s = %q, want %q", name, actual, value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// deploymentParams are computed during the reconciliation and shape the generated Deployment.
+type deploymentParams struct {
+	// image is the application image, the base image for applications run from source.
+	image string
+	// gitSyncImage is the image of the git-sync containers of applications run from git.
+	gitSyncImage string
+	// configHash is the hash of the referenced Secrets and ConfigMaps, changing it rolls the pods.
+	configHash string
+	// resources are the effective resources of the application container.
+	resources corev1.ResourceRequirements
+	// replicas is nil while the HorizontalPodAutoscaler manages the replicas.
+	replicas *int32
+	// topologySpreadConstraints include the default spread of the controller configuration.
+	topologySpreadConstraints []corev1.TopologySpreadConstraint
+	// strategy is Recreate when the pods mount volumes which can't be shared between nodes.
+	strategy appsv1.DeploymentStrategyType
+}
+
+// createUpdateDeployment applies the Deployment of the application and returns its current state.
+func (r *Reconciler) createUpdateDeployment(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication, params deploymentParams) (*appsv1.Deployment, error) {
+	if params.replicas == nil {
+		if err := r.handOverReplicas(ctx, log, dashApp); err != nil {
+			return nil, err
+		}
+	}
+	if params.strategy == appsv1.RecreateDeploymentStrategyType {
+		if err := r.switchToRecreate(ctx, dashApp); err != nil {
+			return nil, err
+		}
+	}
+	deployment := genDeployment(dashApp, params)
+	if _, err := r.apply(ctx, log, dashApp, deployment); err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+func genDeployment(dashApp *dashv1alpha1.DashApplication, params deploymentParams) *appsv1.Deployment {
+	name := dashApp.Name
+	envVars := genEnvVars(dashApp)
+	livenessProbe, readinessProbe, startupProbe := genProbes(dashApp, envVars)
+	volumes, volumeMounts := genVolumes(dashApp)
+	command, args := containerCommand(dashApp)
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: dashApp.Namespace,
+			Labels:    baseAppLabels(name, dashApp.Spec.Labels),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: params.replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: baseAppLabels(name, nil),
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: baseAppLabels(name, dashApp.Spec.Labels),
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:            name,
+							ImagePullPolicy: imagePullPolicy(dashApp),
+							Image:           params.image,
+							Command:         command,
+							Args:            args,
+							Ports: []corev1.ContainerPort{
+								{
+									ContainerPort: dashApp.Spec.Container.ContainerPort,
+									Protocol:      corev1.ProtocolTCP,
+									Name:          httpPortName,
+								},
+							},
+							Env:             envVars,
+							EnvFrom:         dashApp.Spec.Container.EnvFrom,
+							Resources:       params.resources,
+							LivenessProbe:   livenessProbe,
+							ReadinessProbe:  readinessProbe,
+							StartupProbe:    startupProbe,
+							VolumeMounts:    volumeMounts,
+							SecurityContext: containerSecurityContext(dashApp),
+						},
+					},
+					Volumes:                   volumes,
+					SecurityContext:           podSecurityContext(dashApp),
+					NodeSelector:              dashApp.Spec.NodeSelector,
+					Tolerations:               dashApp.Spec.Tolerations,
+					Affinity:                  dashApp.Spec.Affinity,
+					TopologySpreadConstraints: params.topologySpreadConstraints,
+					PriorityClassName:         dashApp.Spec.PriorityClassName,
+				},
+			},
+		},
+	}
+
+	// The default rolling update is left to the API server, so the defaulted parameters are not
+	// owned by the controller.
+	if params.strategy == appsv1.RecreateDeploymentStrategyType {
+		deployment.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+	}
+
+	applySource(dashApp, &deployment.Spec.Template.Spec, params.gitSyncImage)
+
+	if params.configHash != "" {
+		deployment.Spec.Template.Annotations = map[string]string{
+			ConfigHashAnnotation: params.configHash,
+		}
+	}
+
+	return deployment
+}
+
+// desiredReplicas returns the replicas of the Deployment. Suspended applications, idle ones and
+// those outside their schedule are scaled to zero, nil leaves the replicas to the
+// HorizontalPodAutoscaler.
+func desiredReplicas(dashApp *dashv1alpha1.DashApplication, schedule *dashv1alpha1.ScheduleStatus, idle *dashv1alpha1.IdleStatus) *int32 {
+	if dashApp.Spec.Suspend || scheduledDown(schedule) || (idle != nil && idle.State == dashv1alpha1.IdleStateIdle) {
+		replicas := int32(0)
+		return &replicas
+	}
+	if dashApp.Spec.Autoscaling != nil {
+		// Restore the replicas the autoscaler had before the application was suspended, the
+		// autoscaler takes over again with the next reconciliation.
+		return dashApp.Status.SuspendedReplicas
+	}
+	return dashApp.Spec.Replicas
+}
+
+// imagePullPolicy returns the configured pull policy. Without one the image is pulled on every
+// start, as it always was, the base image of applications run from source is pulled once.
+func imagePullPolicy(dashApp *dashv1alpha1.DashApplication) corev1.PullPolicy {
+	switch {
+	case dashApp.Spec.Container.ImagePullPolicy != "":
+		return dashApp.Spec.Container.ImagePullPolicy
+	case dashApp.Spec.Container.Image == "" && dashApp.Spec.Source != nil:
+		return corev1.PullIfNotPresent
+	}
+	return corev1.PullAlways
+}
+
+// genEnvVars merges the user defined environment variables with the ones managed
+// by the controller. Controller managed variables replace user defined entries
+// with the same name, the order of the remaining user entries is preserved.
+// Defaults like the cache locations are only added when the user sets no entry
+// with the same name.
+func genEnvVars(dashApp *dashv1alpha1.DashApplication) []corev1.EnvVar {
+	managed := append(sourceEnvVars(dashApp), dashEnvVars(dashApp)...)
+
+	overridden := make(map[string]bool, len(managed))
+	for _, env := range managed {
+		overridden[env.Name] = true
+	}
+
+	var envVars []corev1.EnvVar
+	for _, env := range dashApp.Spec.Container.Env {
+		if !overridden[env.Name] {
+			envVars = append(envVars, env)
+		}
+		overridden[env.Name] = true
+	}
+	for _, env := range securityEnvVars(dashApp) {
+		if !overridden[env.Name] {
+			envVars = append(envVars, env)
+		}
+	}
+
+	return append(envVars, managed...)
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// createUpdatePodDisruptionBudget applies the PodDisruptionBudget of the application while the
+// Deployment runs more than one replica. With a single replica a budget would either block node
+// drains or allow evicting the only pod, so it is deleted.
+func (r *Reconciler) createUpdatePodDisruptionBudget(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication, replicas int32) error {
+	if replicas <= 1 {
+		return r.deleteOwned(ctx, log, dashApp, &policyv1.PodDisruptionBudget{ObjectMeta: metav1.ObjectMeta{Name: dashApp.Name, Namespace: dashApp.Namespace}})
+	}
+	_, err := r.apply(ctx, log, dashApp, genPodDisruptionBudget(dashApp))
+	return err
+}
+
+func genPodDisruptionBudget(dashApp *dashv1alpha1.DashApplication) *policyv1.PodDisruptionBudget {
+	spec := policyv1.PodDisruptionBudgetSpec{
+		Selector: &metav1.LabelSelector{
+			MatchLabels: baseAppLabels(dashApp.Name, nil),
+		},
+	}
+	budget := dashApp.Spec.DisruptionBudget
+	switch {
+	case budget != nil && budget.MinAvailable != nil:
+		spec.MinAvailable = budget.MinAvailable
+	case budget != nil && budget.MaxUnavailable != nil:
+		spec.MaxUnavailable = budget.MaxUnavailable
+	default:
+		maxUnavailable := intstr.FromInt(1)
+		spec.MaxUnavailable = &maxUnavailable
+	}
+
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dashApp.Name,
+			Namespace: dashApp.Namespace,
+			Labels:    baseAppLabels(dashApp.Name, nil),
+		},
+		Spec: spec,
+	}
+}
@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// gitRootPath is the directory git-sync checks out into, gitLinkName the symlink git-sync
+	// points at the worktree of the current commit, named after the commit SHA.
+	gitRootPath = "/opt/dash/git"
+	gitLinkName = "current"
+	// gitCredentialsMountPath is the directory the credentials Secret is mounted at.
+	gitCredentialsMountPath = "/etc/git-secret"
+
+	gitCloneContainerName = "git-clone"
+	gitSyncContainerName  = "git-sync"
+
+	defaultGitPeriod = time.Minute
+)
+
+// gitInstallScript installs the requirements of the checked out commit and reports the commit
+// in the termination message of the init container.
+const gitInstallScript = `set -e
+commit=$(basename "$(readlink ` + gitRootPath + `/` + gitLinkName + `)")
+if [ -s "$1/` + dashv1alpha1.SourceRequirementsKey + `" ]; then
+  pip install --no-cache-dir --disable-pip-version-check --target ` + packagesMountPath + ` -r "$1/` + dashv1alpha1.SourceRequirementsKey + `"
+fi
+echo "$commit" > ` + packagesMountPath + `/.commit
+printf %s "$commit" > /dev/termination-log
+`
+
+// gitSupervisorScript runs the application command following the application directory in
+// the arguments and exits when git-sync checked out a new commit, leaving the new commit in the
+// termination message. The restarted container installs the requirements of the new commit
+// unless the init container already did. Exit code 0 is reserved for these restarts, an
+// application which stops by itself is reported as failed.
+const gitSupervisorScript = `dir=$1
+shift
+link=` + gitRootPath + `/` + gitLinkName + `
+packages=` + packagesMountPath + `
+commit=$(basename "$(readlink "$link")")
+printf %s "$commit" > /dev/termination-log
+if [ "$(cat "$packages/.commit" 2>/dev/null)" != "$commit" ]; then
+  rm -rf "$packages"/*
+  if [ -s "$dir/` + dashv1alpha1.SourceRequirementsKey + `" ]; then
+    pip install --no-cache-dir --disable-pip-version-check --target "$packages" -r "$dir/` + dashv1alpha1.SourceRequirementsKey + `" || exit 1
+  fi
+  echo "$commit" > "$packages/.commit"
+fi
+cd "$dir" || exit 1
+"$@" &
+pid=$!
+trap 'kill -TERM $pid; wait $pid; exit $?' TERM INT
+while kill -0 $pid 2>/dev/null; do
+  sleep 5 & wait $!
+  next=$(basename "$(readlink "$link")")
+  if [ "$next" != "$commit" ]; then
+    echo "checked out commit $next, restarting"
+    printf %s "$next" > /dev/termination-log
+    kill -TERM $pid
+    wait $pid
+    exit 0
+  fi
+done
+wait $pid || exit $?
+echo "application exited"
+exit 1
+`
+
+// gitSupervised reports whether the application container runs under gitSupervisorScript.
+func gitSupervised(dashApp *dashv1alpha1.DashApplication) bool {
+	source := dashApp.Spec.Source
+	return source != nil && source.Git != nil && len(dashApp.Spec.Container.Command) == 0
+}
+
+// gitRestart reports whether the container was last restarted by the supervisor for a new
+// commit. The kubelet backs off these restarts like crashes, the container is waiting in
+// CrashLoopBackOff until it is started again.
+func gitRestart(dashApp *dashv1alpha1.DashApplication, status corev1.ContainerStatus) bool {
+	terminated := status.LastTerminationState.Terminated
+	return gitSupervised(dashApp) && status.Name == dashApp.Name && terminated != nil && terminated.ExitCode == 0
+}
+
+// gitSourceDir returns the directory of app.py in the current checkout.
+func gitSourceDir(git *dashv1alpha1.GitSource) string {
+	return path.Join(gitRootPath, gitLinkName, git.SubPath)
+}
+
+func gitPeriod(git *dashv1alpha1.GitSource) time.Duration {
+	if git.Period != nil {
+		return git.Period.Duration
+	}
+	return defaultGitPeriod
+}
+
+// gitSSH reports whether the repository is accessed with SSH.
+func gitSSH(repository string) bool {
+	if strings.HasPrefix(repository, "ssh://") {
+		return true
+	}
+	return !strings.Contains(repository, "://") && strings.Contains(repository, "@")
+}
+
+// gitCredentialsVolume returns the volume and mount of the credentials Secret. Keys missing in
+// the Secret are skipped, so one Secret type works for HTTPS and SSH.
+func gitCredentialsVolume(git *dashv1alpha1.GitSource) (*corev1.Volume, *corev1.VolumeMount) {
+	if git.CredentialsSecretRef == nil {
+		return nil, nil
+	}
+	// The Secret is owned by root, git-sync runs as the pod user.
+	mode := int32(0o444)
+	volume := &corev1.Volume{
+		Name: dashv1alpha1.GitCredentialsVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  git.CredentialsSecretRef.Name,
+				DefaultMode: &mode,
+			},
+		},
+	}
+	mount := &corev1.VolumeMount{Name: dashv1alpha1.GitCredentialsVolumeName, MountPath: gitCredentialsMountPath, ReadOnly: true}
+	return volume, mount
+}
+
+// genGitSyncContainer returns a git-sync container checking out into the source volume. It
+// shares the volumes and security context of the application container, so repositories
+// mounted with spec.volumes can be synced.
+func genGitSyncContainer(dashApp *dashv1alpha1.DashApplication, name, image string, app *corev1.Container, credentials *corev1.VolumeMount) corev1.Container {
+	git := dashApp.Spec.Source.Git
+	ref := git.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	env := []corev1.EnvVar{
+		{Name: "GITSYNC_REPO", Value: git.Repository},
+		{Name: "GITSYNC_REF", Value: ref},
+		{Name: "GITSYNC_ROOT", Value: gitRootPath},
+		{Name: "GITSYNC_LINK", Value: gitLinkName},
+		{Name: "GITSYNC_DEPTH", Value: "1"},
+		{Name: "GITSYNC_PERIOD", Value: gitPeriod(git).String()},
+		{Name: "HOME", Value: tmpMountPath},
+	}
+
+	mounts := append([]corev1.VolumeMount{}, app.VolumeMounts...)
+	if credentials != nil {
+		mounts = append(mounts, *credentials)
+		if gitSSH(git.Repository) {
+			env = append(env,
+				corev1.EnvVar{Name: "GITSYNC_SSH_KEY_FILE", Value: gitCredentialsMountPath + "/" + corev1.SSHAuthPrivateKey},
+				corev1.EnvVar{Name: "GITSYNC_SSH_KNOWN_HOSTS_FILE", Value: gitCredentialsMountPath + "/known_hosts"},
+			)
+		} else {
+			optional := true
+			for _, variable := range []struct{ name, key string }{
+				{"GITSYNC_USERNAME", corev1.BasicAuthUsernameKey},
+				{"GITSYNC_PASSWORD", corev1.BasicAuthPasswordKey},
+			} {
+				env = append(env, corev1.EnvVar{
+					Name: variable.name,
+					ValueFrom: &corev1.EnvVarSource{
+						SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: *git.CredentialsSecretRef,
+							Key:                  variable.key,
+							Optional:             &optional,
+						},
+					},
+				})
+			}
+		}
+	}
+
+	return corev1.Container{
+		Name:            name,
+		Image:           image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Env:             env,
+		VolumeMounts:    mounts,
+		SecurityContext: app.SecurityContext,
+	}
+}
+
+// reconcileSourceStatus reports the commit run by the pods of applications run from git. The
+// commit is taken from the termination messages the install container and the supervisor
+// leave, it is only updated once all running pods agree.
+func (r *Reconciler) reconcileSourceStatus(ctx context.Context, dashApp *dashv1alpha1.DashApplication) (*dashv1alpha1.SourceStatus, error) {
+	if dashApp.Spec.Source == nil || dashApp.Spec.Source.Git == nil {
+		return nil, nil
+	}
+	status := &dashv1alpha1.SourceStatus{}
+	if dashApp.Status.Source != nil {
+		status.Commit = dashApp.Status.Source.Commit
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(dashApp.Namespace), client.MatchingLabels{dashv1alpha1.NameLabel: dashApp.Name}); err != nil {
+		return nil, err
+	}
+	commit := ""
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		podCommit := gitPodCommit(pod, dashApp.Name)
+		if podCommit == "" || (commit != "" && podCommit != commit) {
+			return status, nil
+		}
+		commit = podCommit
+	}
+
+	if commit != "" && commit != status.Commit {
+		r.Recorder.Eventf(dashApp, corev1.EventTypeNormal, "Synced", "Running commit %s", commit)
+		status.Commit = commit
+	}
+	return status, nil
+}
+
+// gitPodCommit returns the commit run by the pod. A restarted application container reports the
+// commit it switched to, otherwise the install container reports the initial commit.
+func gitPodCommit(pod *corev1.Pod, containerName string) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == containerName && status.LastTerminationState.Terminated != nil {
+			if message := strings.TrimSpace(status.LastTerminationState.Terminated.Message); message != "" {
+				return message
+			}
+		}
+	}
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == installContainerName && status.State.Terminated != nil {
+			return strings.TrimSpace(status.State.Terminated.Message)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,497 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newGitApp(repository string) *dashv1alpha1.DashApplication {
+	dashApp := newTestApp("app")
+	dashApp.Spec.Container.Image = ""
+	dashApp.Spec.Source = &dashv1alpha1.Source{
+		Git: &dashv1alpha1.GitSource{Repository: repository, Ref: "main", SubPath: "dashboard"},
+	}
+	return dashApp
+}
+
+func terminated(message string) *corev1.ContainerStateTerminated {
+	return &corev1.ContainerStateTerminated{Message: message}
+}
+
+func gitPod(name, appName string, phase corev1.PodPhase, installMessage, restartMessage string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{dashv1alpha1.NameLabel: appName},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+	if installMessage != "" {
+		pod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+			{Name: gitCloneContainerName, State: corev1.ContainerState{Terminated: terminated("")}},
+			{Name: installContainerName, State: corev1.ContainerState{Terminated: terminated(installMessage)}},
+		}
+	}
+	app := corev1.ContainerStatus{Name: appName}
+	if restartMessage != "" {
+		app.LastTerminationState.Terminated = terminated(restartMessage)
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{app, {Name: gitSyncContainerName}}
+	return pod
+}
+
+func TestGitPodCommit(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want string
+	}{
+		{
+			name: "initial commit of the install container",
+			pod:  gitPod("pod", "app", corev1.PodRunning, "aaa\n", ""),
+			want: "aaa",
+		},
+		{
+			name: "commit the restarted application switched to",
+			pod:  gitPod("pod", "app", corev1.PodRunning, "aaa", " bbb\n"),
+			want: "bbb",
+		},
+		{
+			name: "empty termination message of a crash",
+			pod:  gitPod("pod", "app", corev1.PodRunning, "aaa", " \n"),
+			want: "aaa",
+		},
+		{
+			name: "install container still running",
+			pod:  gitPod("pod", "app", corev1.PodPending, "", ""),
+		},
+		{
+			name: "termination message of another container",
+			pod:  gitPod("pod", "other", corev1.PodRunning, "", "bbb"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gitPodCommit(tt.pod, "app"); got != tt.want {
+				t.Errorf("gitPodCommit() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileSourceStatus(t *testing.T) {
+	deleting := gitPod("deleting", "app", corev1.PodRunning, "old", "")
+	deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	deleting.Finalizers = []string{"test"}
+
+	tests := []struct {
+		name       string
+		previous   string
+		pods       []client.Object
+		want       string
+		wantSynced bool
+	}{
+		{
+			name: "no pods",
+		},
+		{
+			name:       "first commit",
+			pods:       []client.Object{gitPod("a", "app", corev1.PodRunning, "aaa", "")},
+			want:       "aaa",
+			wantSynced: true,
+		},
+		{
+			name:     "unchanged commit",
+			previous: "aaa",
+			pods:     []client.Object{gitPod("a", "app", corev1.PodRunning, "aaa", "")},
+			want:     "aaa",
+		},
+		{
+			name:     "all pods switched",
+			previous: "aaa",
+			pods: []client.Object{
+				gitPod("a", "app", corev1.PodRunning, "aaa", "bbb"),
+				gitPod("b", "app", corev1.PodRunning, "bbb", ""),
+			},
+			want:       "bbb",
+			wantSynced: true,
+		},
+		{
+			name:     "pods disagree",
+			previous: "aaa",
+			pods: []client.Object{
+				gitPod("a", "app", corev1.PodRunning, "aaa", "bbb"),
+				gitPod("b", "app", corev1.PodRunning, "aaa", ""),
+			},
+			want: "aaa",
+		},
+		{
+			name:     "running pod without commit",
+			previous: "aaa",
+			pods: []client.Object{
+				gitPod("a", "app", corev1.PodRunning, "bbb", ""),
+				gitPod("b", "app", corev1.PodRunning, "", ""),
+			},
+			want: "aaa",
+		},
+		{
+			name:     "pending, deleted and other pods are ignored",
+			previous: "aaa",
+			pods: []client.Object{
+				gitPod("a", "app", corev1.PodRunning, "bbb", ""),
+				gitPod("pending", "app", corev1.PodPending, "", ""),
+				gitPod("other", "other", corev1.PodRunning, "ccc", ""),
+				deleting,
+			},
+			want:       "bbb",
+			wantSynced: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, recorder := newTestReconciler(t, tt.pods...)
+			dashApp := newGitApp("https://example.com/app.git")
+			if tt.previous != "" {
+				dashApp.Status.Source = &dashv1alpha1.SourceStatus{Commit: tt.previous}
+			}
+
+			status, err := r.reconcileSourceStatus(context.Background(), dashApp)
+			if err != nil {
+				t.Fatalf("reconcileSourceStatus() error = %v", err)
+			}
+			if status == nil || status.Commit != tt.want {
+				t.Errorf("reconcileSourceStatus() = %+v, want commit %q", status, tt.want)
+			}
+
+			synced := false
+			for len(recorder.Events) > 0 {
+				synced = synced || strings.Contains(<-recorder.Events, "Synced")
+			}
+			if synced != tt.wantSynced {
+				t.Errorf("Synced event = %v, want %v", synced, tt.wantSynced)
+			}
+		})
+	}
+
+	t.Run("no git source", func(t *testing.T) {
+		r, _ := newTestReconciler(t)
+		status, err := r.reconcileSourceStatus(context.Background(), newTestApp("app"))
+		if err != nil || status != nil {
+			t.Errorf("reconcileSourceStatus() = %+v, %v, want nil", status, err)
+		}
+	})
+}
+
+func findContainer(containers []corev1.Container, name string) *corev1.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+func findEnv(env []corev1.EnvVar, name string) *corev1.EnvVar {
+	for i := range env {
+		if env[i].Name == name {
+			return &env[i]
+		}
+	}
+	return nil
+}
+
+func TestApplySourceGit(t *testing.T) {
+	params := deploymentParams{image: "python:3.11", gitSyncImage: "git-sync:v4"}
+
+	t.Run("https", func(t *testing.T) {
+		dashApp := newGitApp("https://example.com/app.git")
+		dashApp.Spec.Source.Git.CredentialsSecretRef = &corev1.LocalObjectReference{Name: "git-credentials"}
+		podSpec := genDeployment(dashApp, params).Spec.Template.Spec
+
+		var initNames []string
+		for _, container := range podSpec.InitContainers {
+			initNames = append(initNames, container.Name)
+		}
+		if want := []string{gitCloneContainerName, installContainerName}; !reflect.DeepEqual(initNames, want) {
+			t.Fatalf("init containers = %v, want %v", initNames, want)
+		}
+		clone := findContainer(podSpec.InitContainers, gitCloneContainerName)
+		if env := findEnv(clone.Env, "GITSYNC_ONE_TIME"); env == nil || env.Value != "true" {
+			t.Errorf("clone GITSYNC_ONE_TIME = %v, want true", env)
+		}
+
+		sidecar := findContainer(podSpec.Containers, gitSyncContainerName)
+		if sidecar == nil {
+			t.Fatal("git-sync sidecar missing")
+		}
+		if sidecar.Image != params.gitSyncImage {
+			t.Errorf("sidecar image = %q, want %q", sidecar.Image, params.gitSyncImage)
+		}
+		if findEnv(sidecar.Env, "GITSYNC_ONE_TIME") != nil {
+			t.Error("sidecar must keep syncing")
+		}
+		for name, want := range map[string]string{
+			"GITSYNC_REPO":   "https://example.com/app.git",
+			"GITSYNC_REF":    "main",
+			"GITSYNC_ROOT":   gitRootPath,
+			"GITSYNC_LINK":   gitLinkName,
+			"GITSYNC_PERIOD": defaultGitPeriod.String(),
+		} {
+			if env := findEnv(sidecar.Env, name); env == nil || env.Value != want {
+				t.Errorf("sidecar %s = %v, want %q", name, env, want)
+			}
+		}
+		for _, name := range []string{"GITSYNC_USERNAME", "GITSYNC_PASSWORD"} {
+			env := findEnv(sidecar.Env, name)
+			if env == nil || env.ValueFrom == nil || env.ValueFrom.SecretKeyRef == nil || env.ValueFrom.SecretKeyRef.Name != "git-credentials" {
+				t.Errorf("sidecar %s = %v, want a reference to the credentials Secret", name, env)
+			}
+		}
+		if findEnv(sidecar.Env, "GITSYNC_SSH_KEY_FILE") != nil {
+			t.Error("HTTPS repository must not use an SSH key")
+		}
+
+		app := &podSpec.Containers[0]
+		if app.Name != dashApp.Name {
+			t.Fatalf("first container = %q, want the application container", app.Name)
+		}
+		dir := gitRootPath + "/" + gitLinkName + "/dashboard"
+		wantCommand := []string{"sh", "-c", gitSupervisorScript, "sh", dir, "python", dashv1alpha1.SourceAppKey}
+		if !reflect.DeepEqual(app.Command, wantCommand) {
+			t.Errorf("application command = %q, want the supervised %q", app.Command, wantCommand[3:])
+		}
+		if app.WorkingDir != dir {
+			t.Errorf("working dir = %q, want %q", app.WorkingDir, dir)
+		}
+
+		var credentials *corev1.Volume
+		for i := range podSpec.Volumes {
+			if podSpec.Volumes[i].Name == dashv1alpha1.GitCredentialsVolumeName {
+				credentials = &podSpec.Volumes[i]
+			}
+		}
+		if credentials == nil || credentials.Secret == nil || credentials.Secret.SecretName != "git-credentials" {
+			t.Errorf("credentials volume = %v, want the credentials Secret", credentials)
+		}
+		for _, container := range []*corev1.Container{clone, sidecar} {
+			mounted := false
+			for _, mount := range container.VolumeMounts {
+				mounted = mounted || mount.Name == dashv1alpha1.GitCredentialsVolumeName && mount.MountPath == gitCredentialsMountPath
+			}
+			if !mounted {
+				t.Errorf("%s doesn't mount the credentials", container.Name)
+			}
+		}
+	})
+
+	t.Run("ssh", func(t *testing.T) {
+		dashApp := newGitApp("git@example.com:org/app.git")
+		dashApp.Spec.Source.Git.CredentialsSecretRef = &corev1.LocalObjectReference{Name: "git-credentials"}
+		sidecar := findContainer(genDeployment(dashApp, params).Spec.Template.Spec.Containers, gitSyncContainerName)
+		if env := findEnv(sidecar.Env, "GITSYNC_SSH_KEY_FILE"); env == nil || env.Value != gitCredentialsMountPath+"/"+corev1.SSHAuthPrivateKey {
+			t.Errorf("GITSYNC_SSH_KEY_FILE = %v", env)
+		}
+		if findEnv(sidecar.Env, "GITSYNC_USERNAME") != nil {
+			t.Error("SSH repository must not use a username")
+		}
+	})
+
+	t.Run("custom command is not supervised", func(t *testing.T) {
+		dashApp := newGitApp("https://example.com/app.git")
+		dashApp.Spec.Container.Command = []string{"python", "main.py"}
+		app := genDeployment(dashApp, params).Spec.Template.Spec.Containers[0]
+		if !reflect.DeepEqual(app.Command, []string{"python", "main.py"}) {
+			t.Errorf("application command = %q, want the custom command", app.Command)
+		}
+	})
+}
+
+// gitRun runs git in dir and returns its trimmed output.
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_NOSYSTEM=1", "HOME="+dir,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// pushCommit commits app.py with the given content in the work tree and pushes it to main.
+func pushCommit(t *testing.T, work, content string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(work, "dashboard", dashv1alpha1.SourceAppKey), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, work, "add", "-A")
+	gitRun(t, work, "commit", "-q", "-m", content)
+	gitRun(t, work, "push", "-q", "origin", "HEAD:refs/heads/main")
+	return gitRun(t, work, "rev-parse", "HEAD")
+}
+
+// gitSync checks out the ref of the git-sync container like git-sync does: a shallow worktree
+// named after the commit and the link switched to it atomically.
+func gitSync(t *testing.T, container *corev1.Container, gitRoot string) {
+	t.Helper()
+	repository := findEnv(container.Env, "GITSYNC_REPO").Value
+	ref := findEnv(container.Env, "GITSYNC_REF").Value
+	depth := findEnv(container.Env, "GITSYNC_DEPTH").Value
+	commit, _, _ := strings.Cut(gitRun(t, gitRoot, "ls-remote", repository, ref), "\t")
+	gitRun(t, gitRoot, "clone", "-q", "--depth", depth, "--branch", ref, repository, commit)
+
+	link := filepath.Join(gitRoot, findEnv(container.Env, "GITSYNC_LINK").Value)
+	next := link + ".tmp"
+	if err := os.Symlink(filepath.Join(gitRoot, commit), next); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(next, link); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGitSourceLocalRepository syncs a local bare repository with a file:// URL and runs the
+// install container and the supervisor against the checkouts.
+func TestGitSourceLocalRepository(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the supervisor poll interval")
+	}
+	for _, command := range []string{"git", "sh"} {
+		if _, err := exec.LookPath(command); err != nil {
+			t.Skipf("%s not available", command)
+		}
+	}
+
+	root := t.TempDir()
+	bare := filepath.Join(root, "repository.git")
+	work := filepath.Join(root, "work")
+	gitRoot := filepath.Join(root, "git")
+	packages := filepath.Join(root, "packages")
+	terminationLog := filepath.Join(root, "termination-log")
+	for _, dir := range []string{bare, filepath.Join(work, "dashboard"), gitRoot, packages} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitRun(t, bare, "init", "-q", "--bare")
+	gitRun(t, work, "init", "-q")
+	gitRun(t, work, "remote", "add", "origin", "file://"+bare)
+	first := pushCommit(t, work, "print('first')")
+
+	dashApp := newGitApp("file://" + bare)
+	podSpec := genDeployment(dashApp, deploymentParams{image: "python:3.11", gitSyncImage: "git-sync:v4"}).Spec.Template.Spec
+	clone := findContainer(podSpec.InitContainers, gitCloneContainerName)
+	install := findContainer(podSpec.InitContainers, installContainerName)
+	app := findContainer(podSpec.Containers, dashApp.Name)
+	if clone == nil || install == nil || app == nil {
+		t.Fatalf("missing containers in %+v", podSpec)
+	}
+
+	// the commands run with the paths of the test instead of the volumes
+	paths := strings.NewReplacer(
+		gitRootPath, gitRoot,
+		packagesMountPath, packages,
+		"/dev/termination-log", terminationLog,
+	)
+	command := func(container *corev1.Container, args ...string) *exec.Cmd {
+		replaced := make([]string, 0, len(container.Command)+len(args))
+		for _, arg := range append(append([]string{}, container.Command...), args...) {
+			replaced = append(replaced, paths.Replace(arg))
+		}
+		return exec.Command(replaced[0], replaced[1:]...)
+	}
+	readLog := func() string {
+		message, _ := os.ReadFile(terminationLog)
+		return string(message)
+	}
+
+	gitSync(t, clone, gitRoot)
+	if out, err := command(install).CombinedOutput(); err != nil {
+		t.Fatalf("install: %v\n%s", err, out)
+	}
+	if got := readLog(); got != first {
+		t.Fatalf("install termination message = %q, want %q", got, first)
+	}
+
+	// the supervised python command is replaced, the checkout is no Dash application
+	supervised := len(app.Command) - 2
+	if !reflect.DeepEqual(app.Command[supervised:], []string{"python", dashv1alpha1.SourceAppKey}) {
+		t.Fatalf("application command = %q, want the supervised python command", app.Command)
+	}
+	supervisor := command(&corev1.Container{Command: app.Command[:supervised]}, "sleep", "60")
+	if err := supervisor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- supervisor.Wait() }()
+
+	second := pushCommit(t, work, "print('second')")
+	gitSync(t, findContainer(podSpec.Containers, gitSyncContainerName), gitRoot)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("supervisor exited with %v, want 0 to restart the container", err)
+		}
+	case <-time.After(20 * time.Second):
+		_ = supervisor.Process.Kill()
+		t.Fatal("supervisor didn't exit after the new commit was checked out")
+	}
+	if got := readLog(); got != second {
+		t.Errorf("termination message = %q after the sync, want %q", got, second)
+	}
+
+	// exit code 0 is reserved for restarts with a new commit
+	if err := command(&corev1.Container{Command: app.Command[:supervised]}, "true").Run(); err == nil {
+		t.Error("supervisor exited with 0 after the application stopped by itself")
+	}
+}
+
+func TestPodFailureGitRestart(t *testing.T) {
+	tests := []struct {
+		name       string
+		command    []string
+		exitCode   int32
+		wantReason string
+	}{
+		{name: "restart for a new commit", exitCode: 0},
+		{name: "crash", exitCode: 1, wantReason: "CrashLoopBackOff"},
+		{name: "custom command", command: []string{"python", "main.py"}, exitCode: 0, wantReason: "CrashLoopBackOff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dashApp := newGitApp("https://example.com/repository.git")
+			dashApp.Spec.Container.Command = tt.command
+			pod := gitPod("app-1", dashApp.Name, corev1.PodRunning, "aaa", "bbb")
+			status := &pod.Status.ContainerStatuses[0]
+			status.State.Waiting = &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "back-off restarting failed container"}
+			status.LastTerminationState.Terminated.ExitCode = tt.exitCode
+			r, _ := newTestReconciler(t, dashApp, pod)
+
+			reason, _, err := r.podFailure(context.Background(), dashApp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// idleEnabled reports whether the application is scaled to zero when it receives no requests.
+// Scale-to-zero needs the activator, the setting is ignored when it is disabled and while the
+// application is suspended or outside its schedule, requests can't wake it up then.
+func (r *Reconciler) idleEnabled(dashApp *dashv1alpha1.DashApplication, schedule *dashv1alpha1.ScheduleStatus) bool {
+	return dashApp.Spec.Idle != nil && r.Activator != nil && !dashApp.Spec.Suspend && !scheduledDown(schedule)
+}
+
+// idleAfter returns the period without requests after which the application is scaled to zero.
+func (r *Reconciler) idleAfter(dashApp *dashv1alpha1.DashApplication) time.Duration {
+	if dashApp.Spec.Idle.After != nil {
+		return dashApp.Spec.Idle.After.Duration
+	}
+	return r.Config.Activator.DefaultIdleAfter.Duration
+}
+
+// reconcileIdle registers the application at the activator and decides whether it is scaled to
+// zero. An application is idle when the activator served no request for the idle period and
+// none is in flight. It returns the time after which the decision has to be re-evaluated.
+func (r *Reconciler) reconcileIdle(log logr.Logger, dashApp *dashv1alpha1.DashApplication, schedule *dashv1alpha1.ScheduleStatus, now time.Time) (*dashv1alpha1.IdleStatus, time.Duration, error) {
+	key := client.ObjectKeyFromObject(dashApp)
+	if !r.idleEnabled(dashApp, schedule) {
+		if r.Activator != nil {
+			r.Activator.Unregister(key)
+		}
+		return nil, 0, nil
+	}
+
+	previous := dashApp.Status.Idle
+	if previous == nil {
+		previous = &dashv1alpha1.IdleStatus{}
+	}
+	lastRequest := lastRequestTime(dashApp)
+
+	port, err := r.Activator.Register(key, previous.ActivatorPort, lastRequest)
+	if err != nil {
+		r.Recorder.Eventf(dashApp, corev1.EventTypeWarning, "FailedActivatorRegistration", "Failed to register application at the activator: %v", err)
+		return nil, 0, err
+	}
+	if served := r.Activator.LastRequest(key); served.After(lastRequest) {
+		lastRequest = served
+	}
+
+	status := &dashv1alpha1.IdleStatus{
+		State:           dashv1alpha1.IdleStateActive,
+		LastRequestTime: &metav1.Time{Time: lastRequest},
+		ActivatorPort:   port,
+	}
+
+	idleAt := lastRequest.Add(r.idleAfter(dashApp))
+	if r.Activator.Pending(key) > 0 || now.Before(idleAt) {
+		if previous.State == dashv1alpha1.IdleStateIdle {
+			log.Info("wake application")
+			r.Recorder.Event(dashApp, corev1.EventTypeNormal, "Waking", "Scaling up from zero to serve a request")
+			status.State = dashv1alpha1.IdleStateWaking
+		}
+		if previous.State == dashv1alpha1.IdleStateWaking {
+			status.State = dashv1alpha1.IdleStateWaking
+		}
+		return status, idleAt.Sub(now), nil
+	}
+
+	status.State = dashv1alpha1.IdleStateIdle
+	status.IdleSince = previous.IdleSince
+	if previous.State != dashv1alpha1.IdleStateIdle || status.IdleSince == nil {
+		log.Info("scale idle application to zero", "lastRequest", lastRequest)
+		r.Recorder.Eventf(dashApp, corev1.EventTypeNormal, "Idle", "Scaling to zero after %s without requests", r.idleAfter(dashApp))
+		status.IdleSince = &metav1.Time{Time: now}
+	}
+	return status, 0, nil
+}
+
+// lastRequestTime returns the time of the last request recorded in the status, the creation
+// time of applications which haven't received a request yet.
+func lastRequestTime(dashApp *dashv1alpha1.DashApplication) time.Time {
+	lastRequest := dashApp.CreationTimestamp.Time
+	if idle := dashApp.Status.Idle; idle != nil && idle.LastRequestTime != nil && idle.LastRequestTime.After(lastRequest) {
+		lastRequest = idle.LastRequestTime.Time
+	}
+	return lastRequest
+}
+
+// activatorFailover points the Services of all applications served by the activator at this
+// controller pod when it becomes the leader. Their Endpoints hold the IP of the previous leader
+// until then. The reconciliation of all applications, which starts with the leadership as
+// well, would get there too, but only after working through the queue of every application.
+type activatorFailover struct {
+	reconciler *Reconciler
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, only the leader serves the
+// activator.
+func (f *activatorFailover) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable. Failures are left to the regular reconciliation.
+func (f *activatorFailover) Start(ctx context.Context) error {
+	r := f.reconciler
+	dashApps := &dashv1alpha1.DashApplicationList{}
+	if err := r.List(ctx, dashApps); err != nil {
+		r.Log.Error(err, "failed to list applications served by the activator")
+		return nil
+	}
+	for i := range dashApps.Items {
+		dashApp := &dashApps.Items[i]
+		if !dashApp.DeletionTimestamp.IsZero() {
+			continue
+		}
+		log := r.Log.WithValues("Dash", client.ObjectKeyFromObject(dashApp))
+		var port int32
+		var err error
+		switch {
+		case r.idleEnabled(dashApp, dashApp.Status.Schedule) && dashApp.Status.Idle != nil:
+			port, err = r.Activator.Register(client.ObjectKeyFromObject(dashApp), dashApp.Status.Idle.ActivatorPort, lastRequestTime(dashApp))
+		case r.pausedPageEnabled(dashApp):
+			port = r.Activator.PausedPagePort()
+		default:
+			continue
+		}
+		if err == nil {
+			err = r.createUpdateActivatorEndpoints(ctx, log, dashApp, port)
+		}
+		if err != nil {
+			log.Error(err, "failed to point the application at the activator")
+		}
+	}
+	return nil
+}
+
+// createUpdateActivatorEndpoints points the selectorless application Service at the activator port.
+func (r *Reconciler) createUpdateActivatorEndpoints(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication, port int32) error {
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dashApp.Name,
+			Namespace: dashApp.Namespace,
+			Labels:    baseAppLabels(dashApp.Name, nil),
+		},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{{IP: r.ActivatorAddress}},
+			Ports: []corev1.EndpointPort{{
+				Port:     port,
+				Protocol: corev1.ProtocolTCP,
+			}},
+		}},
+	}
+	_, err := r.apply(ctx, log, dashApp, endpoints)
+	return err
+}
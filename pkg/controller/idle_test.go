@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	"github.com/pluralsh/dash-controller/pkg/activator"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// freePort returns a port nothing listens on.
+func freePort(t *testing.T) int32 {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return int32(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func TestActivatorFailover(t *testing.T) {
+	idle := newTestApp("idle")
+	idle.Spec.Idle = &dashv1alpha1.Idle{}
+	idle.Status.Idle = &dashv1alpha1.IdleStatus{State: dashv1alpha1.IdleStateIdle, ActivatorPort: 20001}
+	paused := newTestApp("paused")
+	paused.Spec.Suspend = true
+	paused.Spec.PausedPage = true
+	active := newTestApp("active")
+
+	// Endpoints of the previous leader
+	previous := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: idle.Name, Namespace: idle.Namespace},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}},
+			Ports:     []corev1.EndpointPort{{Port: 20001, Protocol: corev1.ProtocolTCP}},
+		}},
+	}
+	r, _ := newTestReconciler(t, idle, paused, active, previous)
+	port := freePort(t)
+	r.Config.Activator.MinPort = port
+	r.Config.Activator.MaxPort = port
+	r.Activator = activator.New(r.Client, r.Log, r.Config.Activator)
+	r.ActivatorAddress = "10.0.0.2"
+
+	t.Cleanup(func() { r.Activator.Unregister(client.ObjectKeyFromObject(idle)) })
+
+	ctx := context.Background()
+	if err := (&activatorFailover{reconciler: r}).Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		dashApp *dashv1alpha1.DashApplication
+		port    int32
+	}{
+		// the port of the previous leader is taken already, another one is allocated
+		{idle, port},
+		{paused, r.Config.Activator.PausedPagePort},
+	} {
+		endpoints := &corev1.Endpoints{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(tt.dashApp), endpoints); err != nil {
+			t.Fatalf("%s: %v", tt.dashApp.Name, err)
+		}
+		subsets := endpoints.Subsets
+		if len(subsets) != 1 || len(subsets[0].Addresses) != 1 || len(subsets[0].Ports) != 1 {
+			t.Fatalf("%s: subsets = %+v", tt.dashApp.Name, subsets)
+		}
+		if ip := subsets[0].Addresses[0].IP; ip != r.ActivatorAddress {
+			t.Errorf("%s: address = %s, want %s", tt.dashApp.Name, ip, r.ActivatorAddress)
+		}
+		if got := subsets[0].Ports[0].Port; got != tt.port {
+			t.Errorf("%s: port = %d, want %d", tt.dashApp.Name, got, tt.port)
+		}
+	}
+
+	if err := r.Get(ctx, client.ObjectKeyFromObject(active), &corev1.Endpoints{}); !apierrors.IsNotFound(err) {
+		t.Errorf("active: get Endpoints: %v, want not found", err)
+	}
+}
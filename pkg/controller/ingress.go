@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// createUpdateIngress applies the ingress of the application. While an older application
+// claims an overlapping route the ingress is deleted, also when it was created before the
+// conflict, so only the older application is served.
+func (r *Reconciler) createUpdateIngress(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication, conflict *dashv1alpha1.DashApplication) error {
+	ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: dashApp.Name, Namespace: dashApp.Namespace}}
+	if dashApp.Spec.Ingress == nil {
+		return r.deleteOwned(ctx, log, dashApp, ingress)
+	}
+	if conflict != nil {
+		log.Info("remove ingress, route conflicts with an older application", "application", client.ObjectKeyFromObject(conflict))
+		return r.deleteOwned(ctx, log, dashApp, ingress)
+	}
+	_, err := r.apply(ctx, log, dashApp, genIngress(dashApp))
+	return err
+}
+
+func genIngress(dashApp *dashv1alpha1.DashApplication) *networkingv1.Ingress {
+	prefix := networkingv1.PathTypePrefix
+	path := "/"
+	if dashApp.Spec.Ingress.Path != "" {
+		path = dashApp.Spec.Ingress.Path
+	}
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        dashApp.Name,
+			Namespace:   dashApp.Namespace,
+			Labels:      baseAppLabels(dashApp.Name, nil),
+			Annotations: dashApp.Spec.Ingress.Annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: dashApp.Spec.Ingress.IngressClassName,
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: dashApp.Spec.Ingress.Host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     path,
+									PathType: &prefix,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: dashApp.Name,
+											Port: networkingv1.ServiceBackendPort{
+												Number: 80,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if dashApp.Spec.Ingress.TLS != nil {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{
+			{
+				Hosts:      []string{dashApp.Spec.Ingress.TLS.Host},
+				SecretName: dashApp.Spec.Ingress.TLS.SecretName,
+			},
+		}
+	}
+
+	return ingress
+}
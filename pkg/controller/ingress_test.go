@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestCreateUpdateIngressConflictAfterCreation(t *testing.T) {
+	older := newTestApp("older")
+	older.CreationTimestamp = metav1.Unix(100, 0)
+	older.Spec.Ingress = &dashv1alpha1.Ingress{Host: "example.com", Path: "/app"}
+
+	newer := newTestApp("newer")
+	newer.CreationTimestamp = metav1.Unix(200, 0)
+	newer.Spec.Ingress = &dashv1alpha1.Ingress{Host: "example.com", Path: "/app/sub"}
+
+	tests := []struct {
+		name        string
+		owner       *dashv1alpha1.DashApplication
+		conflict    *dashv1alpha1.DashApplication
+		wantDeleted bool
+	}{
+		{
+			name:        "owned ingress is removed on conflict",
+			owner:       newer,
+			conflict:    older,
+			wantDeleted: true,
+		},
+		{
+			name:     "ingress not controlled by the application is kept",
+			owner:    older,
+			conflict: older,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the ingress was created before the older application claimed the route
+			existing := genIngress(newer)
+			r, _ := newTestReconciler(t)
+			if err := controllerutil.SetControllerReference(tt.owner, existing, r.Scheme); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Create(context.Background(), existing); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := r.createUpdateIngress(context.Background(), r.Log, newer, tt.conflict); err != nil {
+				t.Fatalf("createUpdateIngress() error = %v", err)
+			}
+
+			err := r.Get(context.Background(), client.ObjectKeyFromObject(existing), &networkingv1.Ingress{})
+			if deleted := apierrors.IsNotFound(err); deleted != tt.wantDeleted {
+				t.Errorf("ingress deleted = %v, want %v (get error %v)", deleted, tt.wantDeleted, err)
+			}
+		})
+	}
+}
+
+func TestSetRouteConflictCondition(t *testing.T) {
+	older := newTestApp("older")
+	older.Spec.Ingress = &dashv1alpha1.Ingress{Path: "/app"}
+	newer := newTestApp("newer")
+	newer.Spec.Ingress = &dashv1alpha1.Ingress{Path: "/app/sub"}
+
+	setRouteConflictCondition(newer, nil)
+	if hasRouteConflict(newer) {
+		t.Fatal("expected no route conflict")
+	}
+	setRouteConflictCondition(newer, older)
+	if !hasRouteConflict(newer) {
+		t.Fatal("expected a route conflict after an older application claimed the route")
+	}
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"strings"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// dashLayoutEndpoint is served by every Dash application below the routes prefix. It only
+// answers once the app layout is built, so it covers slow imports at startup.
+const dashLayoutEndpoint = "_dash-layout"
+
+// genProbes returns the liveness, readiness and startup probes of the application container.
+// User defined probes replace the defaults, probes without a handler inherit the default handler.
+// The layout endpoint runs the layout callbacks of the application, the liveness probe only
+// checks the port, so a slow layout takes the pod out of the Service without restarting it.
+func genProbes(dashApp *dashv1alpha1.DashApplication, envVars []corev1.EnvVar) (liveness, readiness, startup *corev1.Probe) {
+	tcpHandler := corev1.ProbeHandler{
+		TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString(httpPortName)},
+	}
+	handler := corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path:   routesPathnamePrefix(envVars) + dashLayoutEndpoint,
+			Port:   intstr.FromString(httpPortName),
+			Scheme: corev1.URISchemeHTTP,
+		},
+	}
+
+	liveness = mergeProbe(dashApp.Spec.Container.LivenessProbe, &corev1.Probe{
+		ProbeHandler:     tcpHandler,
+		TimeoutSeconds:   10,
+		PeriodSeconds:    20,
+		FailureThreshold: 3,
+	})
+	readiness = mergeProbe(dashApp.Spec.Container.ReadinessProbe, &corev1.Probe{
+		ProbeHandler:     handler,
+		TimeoutSeconds:   5,
+		PeriodSeconds:    10,
+		FailureThreshold: 3,
+	})
+	// Dash apps often load data or import heavy libraries before serving, allow five minutes.
+	startup = mergeProbe(dashApp.Spec.Container.StartupProbe, &corev1.Probe{
+		ProbeHandler:     handler,
+		TimeoutSeconds:   5,
+		PeriodSeconds:    10,
+		FailureThreshold: 30,
+	})
+	return liveness, readiness, startup
+}
+
+// mergeProbe returns the user defined probe, using the handler of the default probe when the
+// user probe only tunes timings and thresholds.
+func mergeProbe(probe, defaultProbe *corev1.Probe) *corev1.Probe {
+	if probe == nil {
+		return defaultProbe
+	}
+	probe = probe.DeepCopy()
+	if probe.HTTPGet == nil && probe.TCPSocket == nil && probe.Exec == nil && probe.GRPC == nil {
+		probe.ProbeHandler = defaultProbe.ProbeHandler
+	}
+	return probe
+}
+
+// routesPathnamePrefix returns the path prefix Dash serves its routes on, taken from the
+// DASH_ROUTES_PATHNAME_PREFIX variable of the container.
+func routesPathnamePrefix(envVars []corev1.EnvVar) string {
+	for _, env := range envVars {
+		if env.Name == "DASH_ROUTES_PATHNAME_PREFIX" && env.ValueFrom == nil && strings.HasPrefix(env.Value, "/") {
+			if !strings.HasSuffix(env.Value, "/") {
+				return env.Value + "/"
+			}
+			return env.Value
+		}
+	}
+	return "/"
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestGenProbes(t *testing.T) {
+	dashApp := newTestApp("app")
+	envVars := []corev1.EnvVar{{Name: "DASH_ROUTES_PATHNAME_PREFIX", Value: "/app/"}}
+	liveness, readiness, startup := genProbes(dashApp, envVars)
+
+	if liveness.HTTPGet != nil || liveness.TCPSocket == nil || liveness.TCPSocket.Port != intstr.FromString(httpPortName) {
+		t.Errorf("liveness handler = %+v, want a TCP check of the http port", liveness.ProbeHandler)
+	}
+	for name, probe := range map[string]*corev1.Probe{"readiness": readiness, "startup": startup} {
+		if probe.HTTPGet == nil || probe.HTTPGet.Path != "/app/"+dashLayoutEndpoint {
+			t.Errorf("%s handler = %+v, want an HTTP GET of the layout", name, probe.ProbeHandler)
+		}
+	}
+
+	// a user probe without handler keeps the default handler
+	dashApp.Spec.Container.LivenessProbe = &corev1.Probe{PeriodSeconds: 60}
+	liveness, _, _ = genProbes(dashApp, envVars)
+	if liveness.PeriodSeconds != 60 || liveness.TCPSocket == nil {
+		t.Errorf("liveness = %+v, want the TCP handler with the user timings", liveness)
+	}
+}
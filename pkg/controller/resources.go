@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"fmt"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// resourceProfile returns the name of the resource profile used by the application.
+func (r *Reconciler) resourceProfile(dashApp *dashv1alpha1.DashApplication) string {
+	container := dashApp.Spec.Container
+	if container.ResourceProfile != "" {
+		return container.ResourceProfile
+	}
+	if len(container.Resources.Requests) == 0 && len(container.Resources.Limits) == 0 {
+		return r.Config.DefaultResourceProfile
+	}
+	return ""
+}
+
+// containerResources returns the effective resources of the application container. The
+// requests and limits of the container spec are merged on top of the resource profile. Profile
+// values conflicting with an explicit request or limit are adjusted to it, so the result stays valid.
+func (r *Reconciler) containerResources(dashApp *dashv1alpha1.DashApplication) (corev1.ResourceRequirements, error) {
+	resources := corev1.ResourceRequirements{}
+	if name := r.resourceProfile(dashApp); name != "" {
+		profile, ok := r.Config.ResourceProfiles[name]
+		if !ok {
+			return resources, fmt.Errorf("resource profile %q is not defined, available profiles are %v", name, sortedKeys(r.Config.ResourceProfiles))
+		}
+		resources = *profile.DeepCopy()
+	}
+
+	override := dashApp.Spec.Container.Resources
+	for resourceName, quantity := range override.Requests {
+		if resources.Requests == nil {
+			resources.Requests = corev1.ResourceList{}
+		}
+		resources.Requests[resourceName] = quantity
+		if limit, ok := resources.Limits[resourceName]; ok && limit.Cmp(quantity) < 0 {
+			if _, explicit := override.Limits[resourceName]; !explicit {
+				resources.Limits[resourceName] = quantity
+			}
+		}
+	}
+	for resourceName, quantity := range override.Limits {
+		if resources.Limits == nil {
+			resources.Limits = corev1.ResourceList{}
+		}
+		resources.Limits[resourceName] = quantity
+		if request, ok := resources.Requests[resourceName]; ok && request.Cmp(quantity) > 0 {
+			if _, explicit := override.Requests[resourceName]; !explicit {
+				resources.Requests[resourceName] = quantity
+			}
+		}
+	}
+
+	return resources, nil
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// routeHostIndex indexes DashApplications with an ingress by ingress class and host.
+const routeHostIndex = ".spec.ingress.host"
+
+// routeHostKey returns the index key of the application route. Routes served by different
+// ingress classes never conflict.
+func routeHostKey(dashApp *dashv1alpha1.DashApplication) []string {
+	if dashApp.Spec.Ingress == nil {
+		return nil
+	}
+	class := ""
+	if dashApp.Spec.Ingress.IngressClassName != nil {
+		class = *dashApp.Spec.Ingress.IngressClassName
+	}
+	return []string{fmt.Sprintf("%s/%s", class, strings.ToLower(dashApp.Spec.Ingress.Host))}
+}
+
+// ingressPath returns the path the application is served on, without trailing slash.
+func ingressPath(dashApp *dashv1alpha1.DashApplication) string {
+	if dashApp.Spec.Ingress == nil || dashApp.Spec.Ingress.Path == "" || dashApp.Spec.Ingress.Path == "/" {
+		return "/"
+	}
+	return strings.TrimSuffix(dashApp.Spec.Ingress.Path, "/")
+}
+
+// pathsOverlap reports whether two prefix paths match a common request path, e.g. /app and /app/sub.
+func pathsOverlap(a, b string) bool {
+	if a == b || a == "/" || b == "/" {
+		return true
+	}
+	return strings.HasPrefix(b, a+"/") || strings.HasPrefix(a, b+"/")
+}
+
+// olderThan orders applications by creation time, using the namespaced name as tie breaker.
+func olderThan(a, b *dashv1alpha1.DashApplication) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return client.ObjectKeyFromObject(a).String() < client.ObjectKeyFromObject(b).String()
+}
+
+// routeConflict returns the oldest application which claims an overlapping host and path.
+// Only conflicts with older applications are reported, the older application keeps the route.
+func (r *Reconciler) routeConflict(ctx context.Context, dashApp *dashv1alpha1.DashApplication) (*dashv1alpha1.DashApplication, error) {
+	keys := routeHostKey(dashApp)
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	dashApps := &dashv1alpha1.DashApplicationList{}
+	if err := r.List(ctx, dashApps, client.MatchingFields{routeHostIndex: keys[0]}); err != nil {
+		return nil, err
+	}
+
+	var conflict *dashv1alpha1.DashApplication
+	path := ingressPath(dashApp)
+	for i := range dashApps.Items {
+		other := &dashApps.Items[i]
+		if other.UID == dashApp.UID || !other.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if !pathsOverlap(path, ingressPath(other)) || !olderThan(other, dashApp) {
+			continue
+		}
+		if conflict == nil || olderThan(other, conflict) {
+			conflict = other
+		}
+	}
+
+	return conflict, nil
+}
+
+// setRouteConflictCondition records the route conflict in the application status.
+func setRouteConflictCondition(dashApp *dashv1alpha1.DashApplication, conflict *dashv1alpha1.DashApplication) {
+	if dashApp.Spec.Ingress == nil {
+		meta.RemoveStatusCondition(&dashApp.Status.Conditions, dashv1alpha1.RouteConflictCondition)
+		return
+	}
+
+	condition := metav1.Condition{
+		Type:               dashv1alpha1.RouteConflictCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             "RouteAvailable",
+		Message:            "no other application claims the ingress host and path",
+		ObservedGeneration: dashApp.Generation,
+	}
+	if conflict != nil {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "RouteConflict"
+		condition.Message = fmt.Sprintf("host %q and path %q overlap with path %q of the older application %s, the ingress is removed until the conflict is resolved",
+			dashApp.Spec.Ingress.Host, ingressPath(dashApp), ingressPath(conflict), client.ObjectKeyFromObject(conflict))
+	}
+	meta.SetStatusCondition(&dashApp.Status.Conditions, condition)
+}
+
+// hasRouteConflict reports whether the application route is blocked by an older application.
+func hasRouteConflict(dashApp *dashv1alpha1.DashApplication) bool {
+	return meta.IsStatusConditionTrue(dashApp.Status.Conditions, dashv1alpha1.RouteConflictCondition)
+}
+
+// requestsForSharedHost enqueues all applications using the same ingress class and host, so they
+// re-evaluate their route conflicts when an application changes or is removed.
+func (r *Reconciler) requestsForSharedHost(obj client.Object) []reconcile.Request {
+	keys := routeHostKey(obj.(*dashv1alpha1.DashApplication))
+	if len(keys) == 0 {
+		return nil
+	}
+
+	dashApps := &dashv1alpha1.DashApplicationList{}
+	if err := r.List(context.Background(), dashApps, client.MatchingFields{routeHostIndex: keys[0]}); err != nil {
+		r.Log.Error(err, "failed to list applications sharing the ingress host", "host", keys[0])
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, dashApp := range dashApps.Items {
+		if dashApp.UID != obj.GetUID() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&dashApp)})
+		}
+	}
+	return requests
+}
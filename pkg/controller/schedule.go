@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	"github.com/pluralsh/dash-controller/pkg/cron"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reconcileSchedule evaluates the schedule windows of the application at now. A window is up
+// when its last up time is later than its last down time, the application runs while any window
+// is up. It returns the time until the next window boundary.
+func (r *Reconciler) reconcileSchedule(log logr.Logger, dashApp *dashv1alpha1.DashApplication, now time.Time) (*dashv1alpha1.ScheduleStatus, time.Duration, error) {
+	schedule := dashApp.Spec.Schedule
+	if schedule == nil {
+		return nil, 0, nil
+	}
+
+	loc, err := time.LoadLocation(schedule.TimeZone)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unknown time zone %q", schedule.TimeZone)
+	}
+	now = now.In(loc)
+
+	status := &dashv1alpha1.ScheduleStatus{}
+	var next time.Time
+	for i, window := range schedule.Windows {
+		up, err := cron.Parse(window.Up)
+		if err != nil {
+			return nil, 0, fmt.Errorf("window %d up %q: %w", i, window.Up, err)
+		}
+		down, err := cron.Parse(window.Down)
+		if err != nil {
+			return nil, 0, fmt.Errorf("window %d down %q: %w", i, window.Down, err)
+		}
+
+		lastUp, lastDown := up.Prev(now), down.Prev(now)
+		if !lastUp.IsZero() && lastUp.After(lastDown) {
+			status.Up = true
+		}
+		for _, boundary := range []time.Time{up.Next(now), down.Next(now)} {
+			if !boundary.IsZero() && (next.IsZero() || boundary.Before(next)) {
+				next = boundary
+			}
+		}
+	}
+
+	if previous := dashApp.Status.Schedule; previous == nil || previous.Up != status.Up {
+		log.Info("schedule window changed", "up", status.Up)
+		if status.Up {
+			r.Recorder.Event(dashApp, corev1.EventTypeNormal, "ScheduledUp", "Schedule window is up, scaling up")
+		} else {
+			r.Recorder.Event(dashApp, corev1.EventTypeNormal, "ScheduledDown", "No schedule window is up, scaling to zero")
+		}
+	}
+
+	if next.IsZero() {
+		return status, 0, nil
+	}
+	status.NextTransitionTime = &metav1.Time{Time: next}
+	// requeue just after the boundary, so the window has changed when it is evaluated again
+	return status, next.Sub(now) + time.Second, nil
+}
+
+// scheduledDown reports whether the schedule scales the application to zero.
+func scheduledDown(schedule *dashv1alpha1.ScheduleStatus) bool {
+	return schedule != nil && !schedule.Up
+}
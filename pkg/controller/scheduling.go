@@ -0,0 +1,46 @@
+package controller
+
+import (
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// topologySpreadConstraints returns the topology spread constraints of the application pods.
+// Constraints without label selector select the application pods. Applications which may run
+// more than one replica and set no constraints get the default spread of the controller
+// configuration. It depends on the spec rather than the current replicas, so scaling never
+// rolls the pods.
+func (r *Reconciler) topologySpreadConstraints(dashApp *dashv1alpha1.DashApplication) []corev1.TopologySpreadConstraint {
+	selector := &metav1.LabelSelector{MatchLabels: baseAppLabels(dashApp.Name, nil)}
+
+	if len(dashApp.Spec.TopologySpreadConstraints) > 0 {
+		constraints := make([]corev1.TopologySpreadConstraint, 0, len(dashApp.Spec.TopologySpreadConstraints))
+		for _, constraint := range dashApp.Spec.TopologySpreadConstraints {
+			if constraint.LabelSelector == nil {
+				constraint.LabelSelector = selector
+			}
+			constraints = append(constraints, constraint)
+		}
+		return constraints
+	}
+
+	spread := r.Config.TopologySpread
+	if spread.Disabled || !multiReplica(dashApp) {
+		return nil
+	}
+	return []corev1.TopologySpreadConstraint{{
+		MaxSkew:           spread.MaxSkew,
+		TopologyKey:       spread.TopologyKey,
+		WhenUnsatisfiable: spread.WhenUnsatisfiable,
+		LabelSelector:     selector,
+	}}
+}
+
+// multiReplica reports whether the application may run more than one replica.
+func multiReplica(dashApp *dashv1alpha1.DashApplication) bool {
+	if autoscaling := dashApp.Spec.Autoscaling; autoscaling != nil {
+		return autoscaling.MaxReplicas > 1
+	}
+	return dashApp.Spec.Replicas != nil && *dashApp.Spec.Replicas > 1
+}
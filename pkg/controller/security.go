@@ -0,0 +1,113 @@
+package controller
+
+import (
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// tmpMountPath and cacheMountPath are the writable emptyDir volumes mounted while the root
+// filesystem is read-only.
+const (
+	tmpMountPath   = "/tmp"
+	cacheMountPath = "/var/cache/dash"
+)
+
+// securityDefaults reports whether the restricted security defaults apply to the application.
+func securityDefaults(dashApp *dashv1alpha1.DashApplication) bool {
+	return !dashApp.Spec.DisableSecurityDefaults
+}
+
+// podSecurityContext returns the pod security context. Fields set by the user take precedence
+// over the defaults required by the restricted Pod Security Standard. The base image of
+// applications run from source runs as root, they run as a fixed unprivileged user instead.
+// The storage volume is owned by the fsGroup, so the non-root user can write to it.
+func podSecurityContext(dashApp *dashv1alpha1.DashApplication) *corev1.PodSecurityContext {
+	if !securityDefaults(dashApp) {
+		return dashApp.Spec.SecurityContext
+	}
+
+	securityContext := &corev1.PodSecurityContext{}
+	if dashApp.Spec.SecurityContext != nil {
+		securityContext = dashApp.Spec.SecurityContext.DeepCopy()
+	}
+	if securityContext.RunAsNonRoot == nil {
+		securityContext.RunAsNonRoot = boolPtr(true)
+	}
+	if securityContext.RunAsUser == nil && dashApp.Spec.Container.Image == "" && dashApp.Spec.Source != nil {
+		user := int64(sourceUser)
+		securityContext.RunAsUser = &user
+		securityContext.RunAsGroup = &user
+	}
+	if securityContext.FSGroup == nil && dashApp.Spec.Storage != nil {
+		group := int64(sourceUser)
+		if securityContext.RunAsGroup != nil {
+			group = *securityContext.RunAsGroup
+		}
+		securityContext.FSGroup = &group
+		if securityContext.FSGroupChangePolicy == nil {
+			policy := corev1.FSGroupChangeOnRootMismatch
+			securityContext.FSGroupChangePolicy = &policy
+		}
+	}
+	if securityContext.SeccompProfile == nil {
+		securityContext.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	}
+	return securityContext
+}
+
+// containerSecurityContext returns the security context of the application container. Fields
+// set by the user take precedence over the restricted defaults.
+func containerSecurityContext(dashApp *dashv1alpha1.DashApplication) *corev1.SecurityContext {
+	if !securityDefaults(dashApp) {
+		return dashApp.Spec.Container.SecurityContext
+	}
+
+	securityContext := &corev1.SecurityContext{}
+	if dashApp.Spec.Container.SecurityContext != nil {
+		securityContext = dashApp.Spec.Container.SecurityContext.DeepCopy()
+	}
+	if securityContext.AllowPrivilegeEscalation == nil {
+		securityContext.AllowPrivilegeEscalation = boolPtr(false)
+	}
+	if securityContext.Capabilities == nil {
+		securityContext.Capabilities = &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}}
+	}
+	if securityContext.ReadOnlyRootFilesystem == nil {
+		securityContext.ReadOnlyRootFilesystem = boolPtr(true)
+	}
+	return securityContext
+}
+
+// securityVolumes returns the emptyDir volumes and mounts giving a read-only container the
+// writable directories Python and Dash expect, for temporary files and caches.
+func securityVolumes(dashApp *dashv1alpha1.DashApplication) ([]corev1.Volume, []corev1.VolumeMount) {
+	if !securityDefaults(dashApp) {
+		return nil, nil
+	}
+	volumes := []corev1.Volume{
+		{Name: dashv1alpha1.TmpVolumeName, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		{Name: dashv1alpha1.CacheVolumeName, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+	}
+	mounts := []corev1.VolumeMount{
+		{Name: dashv1alpha1.TmpVolumeName, MountPath: tmpMountPath},
+		{Name: dashv1alpha1.CacheVolumeName, MountPath: cacheMountPath},
+	}
+	return volumes, mounts
+}
+
+// securityEnvVars point the caches of common libraries at the writable cache volume. Unlike the
+// variables managed by the controller, user defined entries with the same name take precedence.
+func securityEnvVars(dashApp *dashv1alpha1.DashApplication) []corev1.EnvVar {
+	if !securityDefaults(dashApp) {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{Name: "XDG_CACHE_HOME", Value: cacheMountPath},
+		{Name: "MPLCONFIGDIR", Value: cacheMountPath + "/matplotlib"},
+		{Name: "PYTHONDONTWRITEBYTECODE", Value: "1"},
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
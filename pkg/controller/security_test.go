@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"testing"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestPodSecurityContextStorage(t *testing.T) {
+	group := int64(2000)
+	always := corev1.FSGroupChangeAlways
+	tests := []struct {
+		name        string
+		storage     bool
+		disabled    bool
+		context     *corev1.PodSecurityContext
+		wantFSGroup *int64
+		wantPolicy  *corev1.PodFSGroupChangePolicy
+	}{
+		{
+			name: "no storage",
+		},
+		{
+			name:        "storage",
+			storage:     true,
+			wantFSGroup: int64Ptr(sourceUser),
+			wantPolicy:  fsGroupChangePolicyPtr(corev1.FSGroupChangeOnRootMismatch),
+		},
+		{
+			name:        "storage with run as group",
+			storage:     true,
+			context:     &corev1.PodSecurityContext{RunAsGroup: &group},
+			wantFSGroup: &group,
+			wantPolicy:  fsGroupChangePolicyPtr(corev1.FSGroupChangeOnRootMismatch),
+		},
+		{
+			name:        "user fsGroup",
+			storage:     true,
+			context:     &corev1.PodSecurityContext{FSGroup: &group, FSGroupChangePolicy: &always},
+			wantFSGroup: &group,
+			wantPolicy:  &always,
+		},
+		{
+			name:     "security defaults disabled",
+			storage:  true,
+			disabled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dashApp := &dashv1alpha1.DashApplication{
+				Spec: dashv1alpha1.DashApplicationSpec{
+					Container:               dashv1alpha1.Container{Image: "app:latest"},
+					SecurityContext:         tt.context,
+					DisableSecurityDefaults: tt.disabled,
+				},
+			}
+			if tt.storage {
+				dashApp.Spec.Storage = &dashv1alpha1.Storage{Size: resource.MustParse("1Gi"), MountPath: "/data"}
+			}
+
+			podSpec := genDeployment(dashApp, deploymentParams{image: "app:latest"}).Spec.Template.Spec
+			var fsGroup *int64
+			var policy *corev1.PodFSGroupChangePolicy
+			if podSpec.SecurityContext != nil {
+				fsGroup = podSpec.SecurityContext.FSGroup
+				policy = podSpec.SecurityContext.FSGroupChangePolicy
+			}
+			if !equalPtr(fsGroup, tt.wantFSGroup) {
+				t.Errorf("fsGroup = %v, want %v", deref(fsGroup), deref(tt.wantFSGroup))
+			}
+			if !equalPtr(policy, tt.wantPolicy) {
+				t.Errorf("fsGroupChangePolicy = %v, want %v", deref(policy), deref(tt.wantPolicy))
+			}
+		})
+	}
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+func fsGroupChangePolicyPtr(policy corev1.PodFSGroupChangePolicy) *corev1.PodFSGroupChangePolicy {
+	return &policy
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func deref[T any](p *T) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
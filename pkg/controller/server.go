@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+)
+
+// defaultServerApp is the WSGI application of the Dash idiom server = app.server in app.py.
+const defaultServerApp = "app:server"
+
+// containerCommand returns the command and arguments of the application container. With a server
+// block the WSGI server is started as Python module, so servers installed from requirements of
+// applications run from source are found on the PYTHONPATH. User arguments are passed to the
+// server before the application.
+func containerCommand(dashApp *dashv1alpha1.DashApplication) ([]string, []string) {
+	server := dashApp.Spec.Server
+	if server == nil {
+		return dashApp.Spec.Container.Command, dashApp.Spec.Container.Args
+	}
+
+	app := server.App
+	if app == "" {
+		app = defaultServerApp
+	}
+	listen := fmt.Sprintf("0.0.0.0:%d", dashApp.Spec.Container.ContainerPort)
+
+	var command, args []string
+	switch server.Type {
+	case dashv1alpha1.ServerTypeWaitress:
+		command = []string{"python", "-m", "waitress"}
+		args = []string{"--listen=" + listen}
+		if server.Threads != nil {
+			args = append(args, fmt.Sprintf("--threads=%d", *server.Threads))
+		}
+		if server.Timeout != nil {
+			args = append(args, fmt.Sprintf("--channel-timeout=%d", timeoutSeconds(server)))
+		}
+	default:
+		command = []string{"python", "-m", "gunicorn"}
+		args = []string{"--bind", listen}
+		if server.Workers != nil {
+			args = append(args, "--workers", strconv.Itoa(int(*server.Workers)))
+		}
+		if server.Threads != nil {
+			args = append(args, "--threads", strconv.Itoa(int(*server.Threads)))
+		}
+		if server.Timeout != nil {
+			args = append(args, "--timeout", strconv.Itoa(timeoutSeconds(server)))
+		}
+	}
+	args = append(args, dashApp.Spec.Container.Args...)
+	return command, append(args, app)
+}
+
+// timeoutSeconds rounds the timeout up to whole seconds, the servers don't accept fractions.
+func timeoutSeconds(server *dashv1alpha1.Server) int {
+	seconds := int(server.Timeout.Seconds())
+	if float64(seconds) < server.Timeout.Seconds() {
+		seconds++
+	}
+	return seconds
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// createUpdateService applies the application Service. With an activator port the Service is
+// pointed at the activator instead of the application pods.
+func (r *Reconciler) createUpdateService(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication, activatorPort int32) error {
+	if err := r.applyService(ctx, log, dashApp, generateService(dashApp, activatorPort != 0)); err != nil {
+		return err
+	}
+	// The endpoints controller ignores Services without selector, the endpoints are applied
+	// once the selector is removed.
+	if activatorPort != 0 {
+		return r.createUpdateActivatorEndpoints(ctx, log, dashApp, activatorPort)
+	}
+	return nil
+}
+
+func (r *Reconciler) applyService(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication, newService *corev1.Service) error {
+	svc := &corev1.Service{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(newService), svc); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		svc = nil
+	}
+
+	_, err := r.apply(ctx, log, dashApp, newService.DeepCopy())
+	if err == nil || svc == nil || !apierrors.IsInvalid(err) || serviceType(svc) == newService.Spec.Type {
+		return err
+	}
+
+	// Some type transitions can't be done in place, e.g. when the API server does not clear
+	// the allocated node ports. Recreate the service with the new type instead.
+	log.Info("recreate service", "from", serviceType(svc), "to", newService.Spec.Type, "reason", err.Error())
+	r.Recorder.Eventf(dashApp, corev1.EventTypeNormal, "RecreateService", "Recreating service %s to change its type from %s to %s", svc.Name, serviceType(svc), newService.Spec.Type)
+	if err := r.Delete(ctx, svc, client.Preconditions{UID: &svc.UID}); err != nil && !apierrors.IsNotFound(err) {
+		r.Recorder.Eventf(dashApp, corev1.EventTypeWarning, "FailedDelete", "Failed to delete service %s: %v", svc.Name, err)
+		return err
+	}
+	_, err = r.apply(ctx, log, dashApp, newService)
+	return err
+}
+
+// Generate the desired Service object for the workspace. Services pointed at the activator,
+// for scale-to-zero or the paused page, have no selector. Applications with scale-to-zero stay
+// pointed at the activator while they are active, it records the last request.
+func generateService(dashApp *dashv1alpha1.DashApplication, activated bool) *corev1.Service {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        dashApp.Name,
+			Namespace:   dashApp.Namespace,
+			Labels:      baseAppLabels(dashApp.Name, nil),
+			Annotations: dashApp.Spec.ServiceAnnotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: baseAppLabels(dashApp.Name, nil),
+			// The port number also matches pods of previous controller versions, which named the
+			// container port after the application.
+			Ports: []corev1.ServicePort{{
+				Protocol:   corev1.ProtocolTCP,
+				Port:       80,
+				TargetPort: intstr.FromInt(int(dashApp.Spec.Container.ContainerPort)),
+			}},
+		},
+	}
+
+	if activated {
+		svc.Spec.Selector = nil
+	}
+
+	// The type is always set explicitly, so adding or removing the ingress migrates an existing service.
+	svc.Spec.Type = corev1.ServiceTypeClusterIP
+	if dashApp.Spec.Ingress == nil {
+		svc.Spec.Type = corev1.ServiceTypeLoadBalancer
+	}
+
+	return svc
+}
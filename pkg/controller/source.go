@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// sourceMountPath is the directory the source ConfigMap is mounted at and the working
+	// directory of applications run from source.
+	sourceMountPath = "/opt/dash/app"
+	// packagesMountPath is the directory the requirements are installed into.
+	packagesMountPath = "/opt/dash/packages"
+	// installContainerName is the init container installing the requirements.
+	installContainerName = "install-requirements"
+	// sourceUser is the user applications run from source run as by default, the Python base
+	// images run as root which the restricted security defaults don't allow.
+	sourceUser = 1000
+)
+
+// installScript installs the requirements of the application directory passed as first
+// argument into the packages volume, which is on the PYTHONPATH of the application container.
+const installScript = `set -e
+if [ -s "$1/` + dashv1alpha1.SourceRequirementsKey + `" ]; then
+  pip install --no-cache-dir --disable-pip-version-check --target ` + packagesMountPath + ` -r "$1/` + dashv1alpha1.SourceRequirementsKey + `"
+fi
+`
+
+// sourceConfigMapName returns the name of the ConfigMap holding the application source, the
+// generated one for inline source. It is empty for applications run from an image.
+func sourceConfigMapName(dashApp *dashv1alpha1.DashApplication) string {
+	switch {
+	case dashApp.Spec.Source == nil:
+		return ""
+	case dashApp.Spec.Source.ConfigMapRef != nil:
+		return dashApp.Spec.Source.ConfigMapRef.Name
+	case dashApp.Spec.Source.Inline != nil:
+		return generatedSourceConfigMapName(dashApp)
+	}
+	return ""
+}
+
+func generatedSourceConfigMapName(dashApp *dashv1alpha1.DashApplication) string {
+	return dashApp.Name + "-source"
+}
+
+// createUpdateSourceConfigMap applies the ConfigMap of inline source and deletes it when the
+// application no longer uses inline source.
+func (r *Reconciler) createUpdateSourceConfigMap(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication) error {
+	if dashApp.Spec.Source == nil || dashApp.Spec.Source.Inline == nil {
+		return r.deleteOwned(ctx, log, dashApp, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: generatedSourceConfigMapName(dashApp), Namespace: dashApp.Namespace}})
+	}
+	_, err := r.apply(ctx, log, dashApp, genSourceConfigMap(dashApp))
+	return err
+}
+
+func genSourceConfigMap(dashApp *dashv1alpha1.DashApplication) *corev1.ConfigMap {
+	inline := dashApp.Spec.Source.Inline
+	data := map[string]string{dashv1alpha1.SourceAppKey: inline.App}
+	if inline.Requirements != "" {
+		data[dashv1alpha1.SourceRequirementsKey] = inline.Requirements
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generatedSourceConfigMapName(dashApp),
+			Namespace: dashApp.Namespace,
+			Labels:    baseAppLabels(dashApp.Name, nil),
+		},
+		Data: data,
+	}
+}
+
+// applicationImage returns the image of the application container. Applications run from source
+// default to the base image of the controller configuration.
+func (r *Reconciler) applicationImage(dashApp *dashv1alpha1.DashApplication) string {
+	if dashApp.Spec.Container.Image == "" && dashApp.Spec.Source != nil {
+		return r.Config.Source.BaseImage
+	}
+	return dashApp.Spec.Container.Image
+}
+
+// sourceDir returns the directory of app.py in the application container.
+func sourceDir(dashApp *dashv1alpha1.DashApplication) string {
+	if dashApp.Spec.Source.Git != nil {
+		return gitSourceDir(dashApp.Spec.Source.Git)
+	}
+	return sourceMountPath
+}
+
+// sourceVolumes returns the volumes and mounts of the source and the installed requirements.
+// The source is the ConfigMap or, for git, the directory git-sync checks out into.
+func sourceVolumes(dashApp *dashv1alpha1.DashApplication) ([]corev1.Volume, []corev1.VolumeMount) {
+	if dashApp.Spec.Source == nil {
+		return nil, nil
+	}
+	if git := dashApp.Spec.Source.Git; git != nil {
+		volumes := []corev1.Volume{
+			{Name: dashv1alpha1.SourceVolumeName, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			{Name: dashv1alpha1.PackagesVolumeName, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		}
+		mounts := []corev1.VolumeMount{
+			{Name: dashv1alpha1.SourceVolumeName, MountPath: gitRootPath},
+			{Name: dashv1alpha1.PackagesVolumeName, MountPath: packagesMountPath},
+		}
+		return volumes, mounts
+	}
+
+	volumes := []corev1.Volume{
+		{
+			Name: dashv1alpha1.SourceVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: sourceConfigMapName(dashApp)},
+				},
+			},
+		},
+		{Name: dashv1alpha1.PackagesVolumeName, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+	}
+	mounts := []corev1.VolumeMount{
+		{Name: dashv1alpha1.SourceVolumeName, MountPath: sourceMountPath, ReadOnly: true},
+		{Name: dashv1alpha1.PackagesVolumeName, MountPath: packagesMountPath},
+	}
+	return volumes, mounts
+}
+
+// sourceEnvVars are managed by the controller for applications run from source. app.run of
+// Dash listens on HOST and PORT.
+func sourceEnvVars(dashApp *dashv1alpha1.DashApplication) []corev1.EnvVar {
+	if dashApp.Spec.Source == nil {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{Name: "HOST", Value: "0.0.0.0"},
+		{Name: "PORT", Value: strconv.Itoa(int(dashApp.Spec.Container.ContainerPort))},
+		{Name: "PYTHONPATH", Value: packagesMountPath},
+		{Name: "HOME", Value: tmpMountPath},
+	}
+}
+
+// applySource runs the application container from source: the source is started with python
+// unless a command or server is set, and the requirements are installed by an init container sharing
+// the image, environment, resources and volumes of the application container. Git source is
+// checked out by git-sync before and synced by a sidecar while the application runs.
+func applySource(dashApp *dashv1alpha1.DashApplication, podSpec *corev1.PodSpec, gitSyncImage string) {
+	if dashApp.Spec.Source == nil {
+		return
+	}
+	dir := sourceDir(dashApp)
+	container := &podSpec.Containers[0]
+	install := corev1.Container{
+		Name:            installContainerName,
+		Image:           container.Image,
+		ImagePullPolicy: container.ImagePullPolicy,
+		Command:         []string{"sh", "-c", installScript, "sh", dir},
+		Env:             container.Env,
+		EnvFrom:         container.EnvFrom,
+		Resources:       container.Resources,
+		VolumeMounts:    container.VolumeMounts,
+		SecurityContext: container.SecurityContext,
+	}
+	if len(container.Command) == 0 {
+		container.Command = []string{"python", dashv1alpha1.SourceAppKey}
+	}
+	container.WorkingDir = dir
+
+	git := dashApp.Spec.Source.Git
+	if git == nil {
+		podSpec.InitContainers = []corev1.Container{install}
+		return
+	}
+
+	// The supervisor restarts the application when git-sync checked out a new commit, unless
+	// the command was replaced.
+	if gitSupervised(dashApp) {
+		supervised := append(container.Command, container.Args...)
+		container.Command = append([]string{"sh", "-c", gitSupervisorScript, "sh", dir}, supervised...)
+		container.Args = nil
+	}
+	install.Command = []string{"sh", "-c", gitInstallScript, "sh", dir}
+
+	credentialsVolume, credentialsMount := gitCredentialsVolume(git)
+	if credentialsVolume != nil {
+		podSpec.Volumes = append(podSpec.Volumes, *credentialsVolume)
+	}
+	clone := genGitSyncContainer(dashApp, gitCloneContainerName, gitSyncImage, container, credentialsMount)
+	clone.Env = append(clone.Env, corev1.EnvVar{Name: "GITSYNC_ONE_TIME", Value: "true"})
+	podSpec.InitContainers = []corev1.Container{clone, install}
+	podSpec.Containers = append(podSpec.Containers, genGitSyncContainer(dashApp, gitSyncContainerName, gitSyncImage, container, credentialsMount))
+}
@@ -0,0 +1,328 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// notReadyRequeueAfter is used to poll the owned resources until the application serves traffic.
+const notReadyRequeueAfter = 30 * time.Second
+
+// podFailureReasons are container waiting reasons which mean the application cannot start
+// without user intervention.
+var podFailureReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// updateStatus derives the DashApplication status from the owned Deployment, Service and Ingress.
+func (r *Reconciler) updateStatus(ctx context.Context, dashApp *dashv1alpha1.DashApplication, configHash string, idle *dashv1alpha1.IdleStatus) error {
+	key := client.ObjectKey{Namespace: dashApp.Namespace, Name: dashApp.Name}
+	status := &dashApp.Status
+	status.ObservedGeneration = dashApp.Generation
+	status.ConfigHash = configHash
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, key, deployment); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		deployment = nil
+	}
+	deploymentCondition, err := r.deploymentCondition(ctx, dashApp, deployment)
+	if err != nil {
+		return err
+	}
+	switch {
+	case dashApp.Spec.Suspend:
+		deploymentCondition = metav1.Condition{Status: metav1.ConditionFalse, Reason: "Suspended", Message: "application is suspended"}
+	case scheduledDown(status.Schedule):
+		deploymentCondition = metav1.Condition{Status: metav1.ConditionFalse, Reason: "OutsideSchedule", Message: "scaled to zero, no schedule window is up"}
+	}
+	if idle != nil {
+		switch {
+		case idle.State == dashv1alpha1.IdleStateIdle:
+			deploymentCondition = metav1.Condition{Status: metav1.ConditionTrue, Reason: "Idle", Message: "scaled to zero, the activator scales the application up on the next request"}
+		case idle.State == dashv1alpha1.IdleStateWaking && deployment != nil && deployment.Status.AvailableReplicas > 0:
+			idle.State = dashv1alpha1.IdleStateActive
+		}
+	}
+	status.Idle = idle
+	deploymentCondition.Type = dashv1alpha1.DeploymentAvailableCondition
+	meta.SetStatusCondition(&status.Conditions, deploymentCondition)
+	status.Replicas = 0
+	status.Selector = labels.SelectorFromSet(baseAppLabels(dashApp.Name, nil)).String()
+	status.ReadyReplicas = 0
+	status.AvailableReplicas = 0
+	status.ResourceProfile = r.resourceProfile(dashApp)
+	status.Resources = nil
+	if deployment != nil {
+		status.Replicas = deployment.Status.Replicas
+		if selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector); err == nil {
+			status.Selector = selector.String()
+		}
+		status.ReadyReplicas = deployment.Status.ReadyReplicas
+		status.AvailableReplicas = deployment.Status.AvailableReplicas
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			if container.Name == dashApp.Name && (len(container.Resources.Requests) > 0 || len(container.Resources.Limits) > 0) {
+				status.Resources = container.Resources.DeepCopy()
+			}
+		}
+	}
+
+	service := &corev1.Service{}
+	if err := r.Get(ctx, key, service); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		service = nil
+	}
+	serviceCondition := serviceCondition(service, status.ServiceType)
+	serviceCondition.Type = dashv1alpha1.ServiceReadyCondition
+	meta.SetStatusCondition(&status.Conditions, serviceCondition)
+	status.ServiceType = ""
+	if service != nil {
+		status.ServiceType = serviceType(service)
+	}
+
+	var ingress *networkingv1.Ingress
+	if dashApp.Spec.Ingress != nil {
+		ingress = &networkingv1.Ingress{}
+		if err := r.Get(ctx, key, ingress); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			ingress = nil
+		}
+		ingressCondition := ingressCondition(ingress)
+		if hasRouteConflict(dashApp) {
+			conflict := meta.FindStatusCondition(status.Conditions, dashv1alpha1.RouteConflictCondition)
+			ingressCondition = metav1.Condition{Status: metav1.ConditionFalse, Reason: conflict.Reason, Message: conflict.Message}
+		}
+		ingressCondition.Type = dashv1alpha1.IngressAdmittedCondition
+		meta.SetStatusCondition(&status.Conditions, ingressCondition)
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, dashv1alpha1.IngressAdmittedCondition)
+	}
+
+	status.URL = applicationURL(dashApp, service, ingress)
+
+	readyCondition := metav1.Condition{
+		Type:    dashv1alpha1.ReadyCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Serving",
+		Message: "application is serving traffic",
+	}
+	for _, conditionType := range []string{dashv1alpha1.DeploymentAvailableCondition, dashv1alpha1.ServiceReadyCondition, dashv1alpha1.IngressAdmittedCondition} {
+		condition := meta.FindStatusCondition(status.Conditions, conditionType)
+		if condition != nil && condition.Status != metav1.ConditionTrue {
+			readyCondition.Status = metav1.ConditionFalse
+			readyCondition.Reason = condition.Reason
+			readyCondition.Message = condition.Message
+			break
+		}
+	}
+	if previous := meta.FindStatusCondition(status.Conditions, dashv1alpha1.ReadyCondition); previous == nil || previous.Status != readyCondition.Status || previous.Reason != readyCondition.Reason {
+		if readyCondition.Status == metav1.ConditionTrue {
+			r.Recorder.Event(dashApp, corev1.EventTypeNormal, "Ready", "Application is serving traffic")
+		} else if podFailureReasons[readyCondition.Reason] {
+			r.Recorder.Event(dashApp, corev1.EventTypeWarning, readyCondition.Reason, readyCondition.Message)
+		}
+	}
+	meta.SetStatusCondition(&status.Conditions, readyCondition)
+
+	status.Ready = readyCondition.Status == metav1.ConditionTrue
+	status.Reason = ""
+	if !status.Ready {
+		status.Reason = readyCondition.Reason
+	}
+
+	for i := range status.Conditions {
+		status.Conditions[i].ObservedGeneration = dashApp.Generation
+	}
+
+	return r.Status().Update(ctx, dashApp)
+}
+
+// updateInvalidSpecStatus marks the application as not ready when its spec can't be turned into
+// resources, e.g. because it references a resource profile unknown to the controller. The
+// generated resources are left as they are until the spec is fixed.
+func (r *Reconciler) updateInvalidSpecStatus(ctx context.Context, dashApp *dashv1alpha1.DashApplication, reason, message string) error {
+	status := &dashApp.Status
+	status.ObservedGeneration = dashApp.Generation
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               dashv1alpha1.ReadyCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: dashApp.Generation,
+	})
+	status.Ready = false
+	status.Reason = reason
+	return r.Status().Update(ctx, dashApp)
+}
+
+// applicationURL returns the address the application is reachable on. With an ingress the URL is
+// built from the ingress host, TLS and path, falling back to the ingress controller address when
+// no host is set. Without an ingress the load balancer address of the service is used.
+func applicationURL(dashApp *dashv1alpha1.DashApplication, service *corev1.Service, ingress *networkingv1.Ingress) string {
+	if dashApp.Spec.Ingress != nil {
+		if ingress == nil || hasRouteConflict(dashApp) {
+			return ""
+		}
+		host := dashApp.Spec.Ingress.Host
+		if host == "" || strings.HasPrefix(host, "*.") {
+			host = loadBalancerAddress(ingress.Status.LoadBalancer)
+		}
+		if host == "" {
+			return ""
+		}
+		scheme := "http"
+		if dashApp.Spec.Ingress.TLS != nil {
+			scheme = "https"
+		}
+		return (&url.URL{Scheme: scheme, Host: host, Path: ingressPath(dashApp)}).String()
+	}
+
+	if service == nil || service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return ""
+	}
+	host := loadBalancerAddress(service.Status.LoadBalancer)
+	if host == "" {
+		return ""
+	}
+	return (&url.URL{Scheme: "http", Host: host, Path: "/"}).String()
+}
+
+func loadBalancerAddress(status corev1.LoadBalancerStatus) string {
+	for _, ingress := range status.Ingress {
+		if ingress.Hostname != "" {
+			return ingress.Hostname
+		}
+		if ingress.IP != "" {
+			return ingress.IP
+		}
+	}
+	return ""
+}
+
+func (r *Reconciler) deploymentCondition(ctx context.Context, dashApp *dashv1alpha1.DashApplication, deployment *appsv1.Deployment) (metav1.Condition, error) {
+	if deployment == nil {
+		return metav1.Condition{Status: metav1.ConditionFalse, Reason: "DeploymentNotFound", Message: "deployment does not exist"}, nil
+	}
+
+	if reason, message, err := r.podFailure(ctx, dashApp); err != nil {
+		return metav1.Condition{}, err
+	} else if reason != "" {
+		return metav1.Condition{Status: metav1.ConditionFalse, Reason: reason, Message: message}, nil
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentReplicaFailure && condition.Status == corev1.ConditionTrue {
+			return metav1.Condition{Status: metav1.ConditionFalse, Reason: condition.Reason, Message: condition.Message}, nil
+		}
+		if condition.Type == appsv1.DeploymentProgressing && condition.Status == corev1.ConditionFalse {
+			return metav1.Condition{Status: metav1.ConditionFalse, Reason: condition.Reason, Message: condition.Message}, nil
+		}
+	}
+
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return metav1.Condition{Status: metav1.ConditionFalse, Reason: "DeploymentProgressing", Message: "waiting for the deployment controller to observe the latest spec"}, nil
+	}
+	if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0 {
+		return metav1.Condition{Status: metav1.ConditionFalse, Reason: "ScaledToZero", Message: "deployment has no desired replicas"}, nil
+	}
+	if deployment.Status.AvailableReplicas == 0 {
+		return metav1.Condition{Status: metav1.ConditionFalse, Reason: "NoAvailableReplicas", Message: "waiting for application pods to become available"}, nil
+	}
+
+	return metav1.Condition{
+		Status:  metav1.ConditionTrue,
+		Reason:  "MinimumReplicasAvailable",
+		Message: fmt.Sprintf("%d/%d replicas available", deployment.Status.AvailableReplicas, deployment.Status.Replicas),
+	}, nil
+}
+
+// podFailure returns the first container waiting reason of the application pods which
+// requires user intervention, like an image that cannot be pulled or a crash looping process.
+// Restarts of applications run from git for a new commit are not failures.
+func (r *Reconciler) podFailure(ctx context.Context, dashApp *dashv1alpha1.DashApplication) (string, string, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(dashApp.Namespace), client.MatchingLabels(baseAppLabels(dashApp.Name, nil))); err != nil {
+		return "", "", err
+	}
+
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, containerStatus := range statuses {
+			waiting := containerStatus.State.Waiting
+			if waiting == nil || !podFailureReasons[waiting.Reason] || gitRestart(dashApp, containerStatus) {
+				continue
+			}
+			message := waiting.Message
+			if terminated := containerStatus.LastTerminationState.Terminated; terminated != nil && terminated.Reason != "" {
+				message = fmt.Sprintf("%s (last termination: %s, exit code %d)", message, terminated.Reason, terminated.ExitCode)
+			}
+			return waiting.Reason, fmt.Sprintf("pod %s container %s: %s", pod.Name, containerStatus.Name, message), nil
+		}
+	}
+
+	return "", "", nil
+}
+
+// serviceCondition reports the service readiness. A type change compared to the previously
+// observed type is reported in the condition until the next status update.
+func serviceCondition(service *corev1.Service, previousType corev1.ServiceType) metav1.Condition {
+	if service == nil {
+		return metav1.Condition{Status: metav1.ConditionFalse, Reason: "ServiceNotFound", Message: "service does not exist"}
+	}
+
+	condition := metav1.Condition{Status: metav1.ConditionTrue, Reason: "ServiceReady", Message: fmt.Sprintf("%s service is ready", serviceType(service))}
+	if service.Spec.Type == corev1.ServiceTypeLoadBalancer && len(service.Status.LoadBalancer.Ingress) == 0 {
+		condition = metav1.Condition{Status: metav1.ConditionFalse, Reason: "LoadBalancerPending", Message: "waiting for the load balancer address"}
+	}
+	if previousType != "" && previousType != serviceType(service) {
+		condition.Message = fmt.Sprintf("service type changed from %s to %s: %s", previousType, serviceType(service), condition.Message)
+		if condition.Status == metav1.ConditionTrue {
+			condition.Reason = "ServiceTypeChanged"
+		}
+	}
+	return condition
+}
+
+func serviceType(service *corev1.Service) corev1.ServiceType {
+	if service.Spec.Type == "" {
+		return corev1.ServiceTypeClusterIP
+	}
+	return service.Spec.Type
+}
+
+func ingressCondition(ingress *networkingv1.Ingress) metav1.Condition {
+	if ingress == nil {
+		return metav1.Condition{Status: metav1.ConditionFalse, Reason: "IngressNotFound", Message: "ingress does not exist"}
+	}
+	if len(ingress.Status.LoadBalancer.Ingress) == 0 {
+		return metav1.Condition{Status: metav1.ConditionFalse, Reason: "IngressPending", Message: "waiting for the ingress controller to admit the ingress"}
+	}
+	return metav1.Condition{Status: metav1.ConditionTrue, Reason: "IngressAdmitted", Message: "ingress has been admitted by the ingress controller"}
+}
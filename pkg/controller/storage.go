@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// createUpdatePersistentVolumeClaim applies the PersistentVolumeClaim of spec.storage and deletes
+// it when the storage is removed. The claim is owned by the application and deleted with it.
+func (r *Reconciler) createUpdatePersistentVolumeClaim(ctx context.Context, log logr.Logger, dashApp *dashv1alpha1.DashApplication) error {
+	if dashApp.Spec.Storage == nil {
+		return r.deleteOwned(ctx, log, dashApp, &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: dashApp.Name, Namespace: dashApp.Namespace}})
+	}
+	_, err := r.apply(ctx, log, dashApp, genPersistentVolumeClaim(dashApp))
+	return err
+}
+
+func genPersistentVolumeClaim(dashApp *dashv1alpha1.DashApplication) *corev1.PersistentVolumeClaim {
+	storage := dashApp.Spec.Storage
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dashApp.Name,
+			Namespace: dashApp.Namespace,
+			Labels:    baseAppLabels(dashApp.Name, nil),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      storageAccessModes(storage),
+			StorageClassName: storage.StorageClassName,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: storage.Size},
+			},
+		},
+	}
+}
+
+func storageAccessModes(storage *dashv1alpha1.Storage) []corev1.PersistentVolumeAccessMode {
+	if len(storage.AccessModes) == 0 {
+		return []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+	return storage.AccessModes
+}
+
+// genVolumes returns the volumes and mounts of the application pods: the source of applications
+// run from source, the user defined ones, the storage claim and the writable directories of the
+// security defaults. User mounts take precedence over default mounts at the same path.
+func genVolumes(dashApp *dashv1alpha1.DashApplication) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes, mounts := sourceVolumes(dashApp)
+	volumes = append(volumes, dashApp.Spec.Volumes...)
+	mounts = append(mounts, dashApp.Spec.Container.VolumeMounts...)
+	if storage := dashApp.Spec.Storage; storage != nil {
+		volumes = append(volumes, corev1.Volume{
+			Name: dashv1alpha1.StorageVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: dashApp.Name},
+			},
+		})
+		mounts = append(mounts, corev1.VolumeMount{Name: dashv1alpha1.StorageVolumeName, MountPath: storage.MountPath})
+	}
+
+	mounted := make(map[string]bool, len(mounts))
+	for _, mount := range mounts {
+		mounted[mount.MountPath] = true
+	}
+	defaultVolumes, defaultMounts := securityVolumes(dashApp)
+	for i, mount := range defaultMounts {
+		if !mounted[mount.MountPath] {
+			volumes = append(volumes, defaultVolumes[i])
+			mounts = append(mounts, mount)
+		}
+	}
+
+	if len(volumes) == 0 {
+		return nil, nil
+	}
+	return volumes, mounts
+}
+
+// deploymentStrategy returns Recreate when the pods mount a volume which can only be attached to
+// a single node, a rolling update would get stuck on the new pod waiting for the volume. Claims
+// which don't exist yet are assumed to be ReadWriteOnce.
+func (r *Reconciler) deploymentStrategy(ctx context.Context, dashApp *dashv1alpha1.DashApplication) (appsv1.DeploymentStrategyType, error) {
+	if storage := dashApp.Spec.Storage; storage != nil && singleNode(storageAccessModes(storage)) {
+		return appsv1.RecreateDeploymentStrategyType, nil
+	}
+	for _, volume := range dashApp.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		claim := &corev1.PersistentVolumeClaim{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: dashApp.Namespace, Name: volume.PersistentVolumeClaim.ClaimName}, claim); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return "", err
+			}
+			return appsv1.RecreateDeploymentStrategyType, nil
+		}
+		if singleNode(claim.Spec.AccessModes) {
+			return appsv1.RecreateDeploymentStrategyType, nil
+		}
+	}
+	return appsv1.RollingUpdateDeploymentStrategyType, nil
+}
+
+// singleNode reports whether a volume with the access modes can't be mounted on multiple nodes.
+func singleNode(accessModes []corev1.PersistentVolumeAccessMode) bool {
+	for _, mode := range accessModes {
+		if mode == corev1.ReadWriteMany || mode == corev1.ReadOnlyMany {
+			return false
+		}
+	}
+	return true
+}
+
+// switchToRecreate clears the rolling update parameters defaulted by the API server, which are
+// not owned by the controller and would be kept by server-side apply, before the strategy type
+// changes to Recreate. Deployments with Recreate strategy must not have them.
+func (r *Reconciler) switchToRecreate(ctx context.Context, dashApp *dashv1alpha1.DashApplication) error {
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(dashApp), deployment); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(deployment, dashApp) || deployment.Spec.Strategy.RollingUpdate == nil {
+		return nil
+	}
+	patch := []byte(`{"spec":{"strategy":{"type":"Recreate","rollingUpdate":null}}}`)
+	return r.Patch(ctx, deployment, client.RawPatch(types.StrategicMergePatchType, patch), client.FieldOwner(FieldManager))
+}
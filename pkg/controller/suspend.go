@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+
+	dashv1alpha1 "github.com/pluralsh/dash-controller/apis/dash/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// pausedPageEnabled reports whether the application Service is pointed at the paused page.
+// The page is served by the activator, it is not shown when the activator is disabled.
+func (r *Reconciler) pausedPageEnabled(dashApp *dashv1alpha1.DashApplication) bool {
+	return dashApp.Spec.Suspend && dashApp.Spec.PausedPage && r.Activator != nil
+}
+
+// suspendedReplicas returns the replicas restored when the application is resumed. They are
+// taken from the Deployment when the application is suspended and kept until it is resumed.
+func (r *Reconciler) suspendedReplicas(ctx context.Context, dashApp *dashv1alpha1.DashApplication) (*int32, error) {
+	if !dashApp.Spec.Suspend {
+		return nil, nil
+	}
+	if dashApp.Status.SuspendedReplicas != nil {
+		return dashApp.Status.SuspendedReplicas, nil
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(dashApp), deployment); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas == 0 {
+		return nil, nil
+	}
+	replicas := *deployment.Spec.Replicas
+	return &replicas, nil
+}
+
+// setSuspendedCondition records whether the application is suspended and emits an event when
+// it is suspended or resumed.
+func (r *Reconciler) setSuspendedCondition(dashApp *dashv1alpha1.DashApplication, suspendedReplicas *int32) {
+	condition := metav1.Condition{
+		Type:               dashv1alpha1.SuspendedCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Running",
+		Message:            "application is not suspended",
+		ObservedGeneration: dashApp.Generation,
+	}
+	if dashApp.Spec.Suspend {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Suspended"
+		condition.Message = "application is suspended and scaled to zero"
+	}
+
+	previous := meta.FindStatusCondition(dashApp.Status.Conditions, dashv1alpha1.SuspendedCondition)
+	switch {
+	case dashApp.Spec.Suspend && (previous == nil || previous.Status != metav1.ConditionTrue):
+		r.Recorder.Event(dashApp, corev1.EventTypeNormal, "Suspended", "Application suspended, scaling to zero")
+	case !dashApp.Spec.Suspend && previous != nil && previous.Status == metav1.ConditionTrue:
+		r.Recorder.Event(dashApp, corev1.EventTypeNormal, "Resumed", "Application resumed")
+	}
+
+	meta.SetStatusCondition(&dashApp.Status.Conditions, condition)
+	dashApp.Status.SuspendedReplicas = suspendedReplicas
+}
@@ -0,0 +1,213 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func date(loc *time.Location, year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, loc)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * foo *",
+		"* * * * sunday",
+		"a * * * *",
+		"-1 * * * *",
+		"5-1 * * * *",
+		"1-x * * * *",
+		"*/0 * * * *",
+		"*/-5 * * * *",
+		"*/x * * * *",
+		"1,,2 * * * *",
+	} {
+		t.Run(spec, func(t *testing.T) {
+			if _, err := Parse(spec); err == nil {
+				t.Errorf("Parse(%q) expected an error", spec)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		// wildcards and steps
+		{"* * * * *", date(time.UTC, 2023, 1, 1, 10, 7), date(time.UTC, 2023, 1, 1, 10, 8)},
+		{"*/15 * * * *", date(time.UTC, 2023, 1, 1, 10, 7), date(time.UTC, 2023, 1, 1, 10, 15)},
+		{"*/15 * * * *", date(time.UTC, 2023, 1, 1, 10, 45), date(time.UTC, 2023, 1, 1, 11, 0)},
+		{"5/15 * * * *", date(time.UTC, 2023, 1, 1, 10, 21), date(time.UTC, 2023, 1, 1, 10, 35)},
+		{"5/15 * * * *", date(time.UTC, 2023, 1, 1, 10, 51), date(time.UTC, 2023, 1, 1, 11, 5)},
+		// ranges, lists and stepped ranges
+		{"0 9-17/4 * * *", date(time.UTC, 2023, 1, 1, 13, 0), date(time.UTC, 2023, 1, 1, 17, 0)},
+		{"0 9-17/4 * * *", date(time.UTC, 2023, 1, 1, 17, 0), date(time.UTC, 2023, 1, 2, 9, 0)},
+		{"10,20 8 * * *", date(time.UTC, 2023, 1, 1, 8, 10), date(time.UTC, 2023, 1, 1, 8, 20)},
+		{"0 0 * * mon-fri", date(time.UTC, 2023, 1, 7, 0, 0), date(time.UTC, 2023, 1, 9, 0, 0)},
+		{"0 0 * * SAT,sun", date(time.UTC, 2023, 1, 2, 0, 0), date(time.UTC, 2023, 1, 7, 0, 0)},
+		{"0 0 1,15 jan *", date(time.UTC, 2023, 1, 2, 0, 0), date(time.UTC, 2023, 1, 15, 0, 0)},
+		{"0 0 1,15 jan *", date(time.UTC, 2023, 1, 15, 0, 0), date(time.UTC, 2024, 1, 1, 0, 0)},
+		// 7 is Sunday
+		{"0 0 * * 7", date(time.UTC, 2023, 1, 2, 0, 0), date(time.UTC, 2023, 1, 8, 0, 0)},
+		{"0 0 * * 5-7", date(time.UTC, 2023, 1, 2, 0, 0), date(time.UTC, 2023, 1, 6, 0, 0)},
+		// months without the day are skipped
+		{"0 0 31 * *", date(time.UTC, 2023, 4, 1, 0, 0), date(time.UTC, 2023, 5, 31, 0, 0)},
+		{"0 0 29 2 *", date(time.UTC, 2023, 1, 1, 0, 0), date(time.UTC, 2024, 2, 29, 0, 0)},
+		// day of month and day of week both restricted match either of them
+		{"0 0 13 * fri", date(time.UTC, 2023, 2, 10, 0, 0), date(time.UTC, 2023, 2, 13, 0, 0)},
+		{"0 0 13 * fri", date(time.UTC, 2023, 2, 13, 0, 0), date(time.UTC, 2023, 2, 17, 0, 0)},
+		// a wildcard with a step doesn't restrict the field, as in Vixie cron, both must match
+		{"0 0 1 * */7", date(time.UTC, 2023, 1, 2, 0, 0), date(time.UTC, 2023, 10, 1, 0, 0)},
+		{"0 0 1 * *", date(time.UTC, 2023, 1, 2, 0, 0), date(time.UTC, 2023, 2, 1, 0, 0)},
+		// never matches
+		{"0 0 30 2 *", date(time.UTC, 2023, 1, 1, 0, 0), time.Time{}},
+		{"0 0 31 4,6,9,11 *", date(time.UTC, 2023, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec+" "+tt.from.Format(time.RFC3339), func(t *testing.T) {
+			schedule, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+			}
+			if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrev(t *testing.T) {
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"0 8 * * mon-fri", date(time.UTC, 2023, 1, 7, 12, 0), date(time.UTC, 2023, 1, 6, 8, 0)},
+		{"0 8 * * mon-fri", date(time.UTC, 2023, 1, 6, 8, 0), date(time.UTC, 2023, 1, 6, 8, 0)},
+		{"0 8 * * mon-fri", date(time.UTC, 2023, 1, 6, 7, 59), date(time.UTC, 2023, 1, 5, 8, 0)},
+		{"*/20 * * * *", date(time.UTC, 2023, 1, 1, 10, 59), date(time.UTC, 2023, 1, 1, 10, 40)},
+		{"0 0 31 * *", date(time.UTC, 2023, 5, 1, 0, 0), date(time.UTC, 2023, 3, 31, 0, 0)},
+		{"0 0 13 * fri", date(time.UTC, 2023, 2, 16, 0, 0), date(time.UTC, 2023, 2, 13, 0, 0)},
+		{"0 0 13 * fri", date(time.UTC, 2023, 2, 12, 0, 0), date(time.UTC, 2023, 2, 10, 0, 0)},
+		{"0 0 30 2 *", date(time.UTC, 2023, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec+" "+tt.from.Format(time.RFC3339), func(t *testing.T) {
+			schedule, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+			}
+			if got := schedule.Prev(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Prev() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// In America/New_York the clocks are turned forward from 02:00 to 03:00 on 2023-03-12 and back
+// from 02:00 to 01:00 on 2023-11-05.
+func TestDaylightSavingTime(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	utc := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return date(time.UTC, year, month, day, hour, minute)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		prev bool
+		from time.Time
+		want time.Time
+	}{
+		{
+			name: "skipped time doesn't match",
+			spec: "30 2 * * *",
+			from: date(loc, 2023, 3, 12, 0, 0),
+			want: utc(2023, 3, 13, 6, 30), // 02:30 EDT
+		},
+		{
+			name: "hourly continues after the skipped hour",
+			spec: "0 * * * *",
+			from: date(loc, 2023, 3, 12, 1, 30),
+			want: utc(2023, 3, 12, 7, 0), // 03:00 EDT
+		},
+		{
+			name: "time after the skipped hour",
+			spec: "30 3 * * *",
+			from: date(loc, 2023, 3, 12, 0, 0),
+			want: utc(2023, 3, 12, 7, 30), // 03:30 EDT
+		},
+		{
+			name: "previous day before the skipped hour",
+			spec: "30 2 * * *",
+			prev: true,
+			from: date(loc, 2023, 3, 12, 12, 0),
+			want: utc(2023, 3, 11, 7, 30), // 02:30 EST
+		},
+		{
+			name: "repeated time matches first",
+			spec: "30 1 * * *",
+			from: date(loc, 2023, 11, 5, 0, 0),
+			want: utc(2023, 11, 5, 5, 30), // 01:30 EDT
+		},
+		{
+			name: "repeated time matches again",
+			spec: "30 1 * * *",
+			from: utc(2023, 11, 5, 5, 30).In(loc),
+			want: utc(2023, 11, 5, 6, 30), // 01:30 EST
+		},
+		{
+			name: "repeated time matches once a day",
+			spec: "30 1 * * *",
+			from: utc(2023, 11, 5, 6, 30).In(loc),
+			want: utc(2023, 11, 6, 6, 30), // 01:30 EST
+		},
+		{
+			name: "time after the repeated hour",
+			spec: "0 2 * * *",
+			from: date(loc, 2023, 11, 5, 0, 0),
+			want: utc(2023, 11, 5, 7, 0), // 02:00 EST
+		},
+		{
+			name: "previous time across the repeated hour",
+			spec: "0 0 * * *",
+			prev: true,
+			from: date(loc, 2023, 11, 5, 3, 0),
+			want: utc(2023, 11, 5, 4, 0), // 00:00 EDT
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+			}
+			got := schedule.Next(tt.from)
+			if tt.prev {
+				got = schedule.Prev(tt.from)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want.In(loc))
+			}
+			if !got.IsZero() && got.Location() != loc {
+				t.Errorf("got location %v, want %v", got.Location(), loc)
+			}
+		})
+	}
+}
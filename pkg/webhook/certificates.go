@@ -0,0 +1,361 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	caCertKey = "ca.crt"
+	caKeyKey  = "ca.key"
+
+	caValidity   = 10 * 365 * 24 * time.Hour
+	certValidity = 365 * 24 * time.Hour
+	// certRenewBefore is the remaining validity below which a new certificate is issued.
+	certRenewBefore = 30 * 24 * time.Hour
+	// certCheckInterval is how often the running controller checks the certificate.
+	certCheckInterval = 10 * time.Minute
+)
+
+// CertificateOptions describe where the webhook serving certificate is stored and which
+// webhook configurations have to trust it.
+type CertificateOptions struct {
+	// Namespace of the webhook Service and certificate Secret.
+	Namespace string
+	// ServiceName is the name of the Service in front of the webhook server.
+	ServiceName string
+	// SecretName is the name of the Secret storing the CA and serving certificate.
+	SecretName string
+	// CertDir is the directory the webhook server reads tls.crt and tls.key from.
+	CertDir string
+	// MutatingWebhookConfiguration and ValidatingWebhookConfiguration are the names of the
+	// configurations the CA bundle is injected into.
+	MutatingWebhookConfiguration   string
+	ValidatingWebhookConfiguration string
+}
+
+// EnsureCertificates provisions a self-signed CA and serving certificate for the webhook
+// server without depending on cert-manager. The certificates are stored in a Secret, so all
+// controller replicas share them, written to the webhook server certificate directory and the
+// CA is injected into the webhook configurations. Certificates are renewed once they are close
+// to expiry, on startup and by the CertificateRenewer.
+func EnsureCertificates(ctx context.Context, client ctrlruntimeclient.Client, log logr.Logger, opts CertificateOptions) error {
+	secret, err := ensureSecret(ctx, client, log, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(opts.CertDir, 0o700); err != nil {
+		return err
+	}
+	for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+		file := filepath.Join(opts.CertDir, key)
+		// the webhook server reloads the certificate when the files change
+		if current, err := os.ReadFile(file); err == nil && bytes.Equal(current, secret.Data[key]) {
+			continue
+		}
+		if err := os.WriteFile(file, secret.Data[key], 0o600); err != nil {
+			return err
+		}
+	}
+
+	return injectCABundle(ctx, client, log, opts, secret.Data[caCertKey])
+}
+
+// CertificateRenewer keeps the webhook certificate valid while the controller runs. It runs on
+// every replica, as all of them serve the webhook, and picks up certificates renewed by
+// another replica from the Secret.
+type CertificateRenewer struct {
+	Client  ctrlruntimeclient.Client
+	Log     logr.Logger
+	Options CertificateOptions
+	// Interval between the checks, defaults to ten minutes.
+	Interval time.Duration
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (r *CertificateRenewer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable. Failed checks are logged and retried with the next one,
+// the current certificate is usually valid for weeks.
+func (r *CertificateRenewer) Start(ctx context.Context) error {
+	interval := r.Interval
+	if interval == 0 {
+		interval = certCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := EnsureCertificates(ctx, r.Client, r.Log, r.Options); err != nil {
+				r.Log.Error(err, "failed to check webhook certificate")
+			}
+		}
+	}
+}
+
+func ensureSecret(ctx context.Context, client ctrlruntimeclient.Client, log logr.Logger, opts CertificateOptions) (*corev1.Secret, error) {
+	key := ctrlruntimeclient.ObjectKey{Namespace: opts.Namespace, Name: opts.SecretName}
+	var secret *corev1.Secret
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret = &corev1.Secret{}
+		if err := client.Get(ctx, key, secret); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			secret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: opts.SecretName, Namespace: opts.Namespace},
+				Type:       corev1.SecretTypeTLS,
+			}
+		}
+
+		if validCertificate(secret, opts) {
+			return nil
+		}
+
+		data, err := generateCertificates(opts, secret.Data)
+		if err != nil {
+			return err
+		}
+		secret.Data = data
+
+		if secret.ResourceVersion == "" {
+			log.Info("create webhook certificate", "secret", key)
+			err = client.Create(ctx, secret)
+			// another replica was faster, retry with its certificate
+			if apierrors.IsAlreadyExists(err) {
+				return apierrors.NewConflict(corev1.Resource("secrets"), opts.SecretName, err)
+			}
+			return err
+		}
+		log.Info("renew webhook certificate", "secret", key)
+		return client.Update(ctx, secret)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to provision webhook certificate %s: %w", key, err)
+	}
+
+	return secret, nil
+}
+
+// validCertificate checks that the stored certificate matches the service DNS name, is signed by
+// the stored CA and is not close to expiry.
+func validCertificate(secret *corev1.Secret, opts CertificateOptions) bool {
+	pair, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return false
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(secret.Data[caCertKey]) {
+		return false
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		DNSName:     serviceDNSName(opts),
+		Roots:       roots,
+		CurrentTime: time.Now().Add(certRenewBefore),
+	})
+	return err == nil
+}
+
+// generateCertificates issues a new serving certificate signed by the CA stored with the
+// previous certificate. Replicas serving the previous certificate keep being trusted while they
+// pick up the new one. A new CA is only created when none is stored or it is close to expiry,
+// the previous CA stays in the bundle until then.
+func generateCertificates(opts CertificateOptions, previous map[string][]byte) (map[string][]byte, error) {
+	now := time.Now()
+
+	caCert, caKey := storedCA(previous, now)
+	caBundle := previous[caCertKey]
+	if caCert == nil {
+		var err error
+		if caKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			return nil, err
+		}
+		caTemplate := &x509.Certificate{
+			SerialNumber:          newSerialNumber(),
+			Subject:               pkix.Name{CommonName: fmt.Sprintf("%s-ca", opts.ServiceName)},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.Add(caValidity),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+		if err != nil {
+			return nil, err
+		}
+		if caCert, err = x509.ParseCertificate(caDER); err != nil {
+			return nil, err
+		}
+		caBundle = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+		if previousCA := firstCertificate(previous[caCertKey]); previousCA != nil && now.Before(previousCA.NotAfter) {
+			caBundle = append(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: previousCA.Raw})...)
+		}
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: newSerialNumber(),
+		Subject:      pkix.Name{CommonName: serviceDNSName(opts)},
+		DNSNames: []string{
+			opts.ServiceName,
+			fmt.Sprintf("%s.%s", opts.ServiceName, opts.Namespace),
+			serviceDNSName(opts),
+			fmt.Sprintf("%s.cluster.local", serviceDNSName(opts)),
+		},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(certValidity),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		caCertKey:               caBundle,
+		caKeyKey:                pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(caKey)}),
+		corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+	}, nil
+}
+
+// storedCA returns the CA signing the serving certificates, the first certificate of the
+// bundle, if its key is stored as well and it outlives a new serving certificate. Secrets
+// created by previous controller versions don't hold the CA key.
+func storedCA(data map[string][]byte, now time.Time) (*x509.Certificate, *rsa.PrivateKey) {
+	cert := firstCertificate(data[caCertKey])
+	block, _ := pem.Decode(data[caKeyKey])
+	if cert == nil || block == nil || !cert.IsCA || now.Add(certValidity).After(cert.NotAfter) {
+		return nil, nil
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil || !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, nil
+	}
+	return cert, key
+}
+
+func firstCertificate(bundle []byte) *x509.Certificate {
+	block, _ := pem.Decode(bundle)
+	if block == nil {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	return cert
+}
+
+func newSerialNumber() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return big.NewInt(time.Now().UnixNano())
+	}
+	return serial
+}
+
+func serviceDNSName(opts CertificateOptions) string {
+	return fmt.Sprintf("%s.%s.svc", opts.ServiceName, opts.Namespace)
+}
+
+// injectCABundle sets the CA bundle on all webhooks of the configured webhook configurations.
+// Missing configurations are skipped, e.g. when the webhooks are not installed.
+func injectCABundle(ctx context.Context, client ctrlruntimeclient.Client, log logr.Logger, opts CertificateOptions, caBundle []byte) error {
+	if opts.MutatingWebhookConfiguration != "" {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			config := &admissionregistrationv1.MutatingWebhookConfiguration{}
+			if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Name: opts.MutatingWebhookConfiguration}, config); err != nil {
+				return err
+			}
+			update := false
+			for i := range config.Webhooks {
+				if !bytes.Equal(config.Webhooks[i].ClientConfig.CABundle, caBundle) {
+					config.Webhooks[i].ClientConfig.CABundle = caBundle
+					update = true
+				}
+			}
+			if !update {
+				return nil
+			}
+			log.Info("inject webhook CA bundle", "mutatingwebhookconfiguration", config.Name)
+			return client.Update(ctx, config)
+		})
+		if err := ignoreNotFound(log, "mutatingwebhookconfiguration", opts.MutatingWebhookConfiguration, err); err != nil {
+			return err
+		}
+	}
+
+	if opts.ValidatingWebhookConfiguration != "" {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			config := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+			if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Name: opts.ValidatingWebhookConfiguration}, config); err != nil {
+				return err
+			}
+			update := false
+			for i := range config.Webhooks {
+				if !bytes.Equal(config.Webhooks[i].ClientConfig.CABundle, caBundle) {
+					config.Webhooks[i].ClientConfig.CABundle = caBundle
+					update = true
+				}
+			}
+			if !update {
+				return nil
+			}
+			log.Info("inject webhook CA bundle", "validatingwebhookconfiguration", config.Name)
+			return client.Update(ctx, config)
+		})
+		if err := ignoreNotFound(log, "validatingwebhookconfiguration", opts.ValidatingWebhookConfiguration, err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ignoreNotFound(log logr.Logger, kind, name string, err error) error {
+	if apierrors.IsNotFound(err) {
+		log.Info("webhook configuration not found, skipping CA injection", kind, name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inject CA bundle into %s %s: %w", kind, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testOptions(t *testing.T) CertificateOptions {
+	return CertificateOptions{
+		Namespace:                      "dash",
+		ServiceName:                    "dash-controller-webhook",
+		SecretName:                     "dash-controller-webhook-cert",
+		CertDir:                        t.TempDir(),
+		ValidatingWebhookConfiguration: "dash-controller-validating",
+	}
+}
+
+func testClient(objs ...ctrlruntimeclient.Object) ctrlruntimeclient.Client {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(scheme))
+	utilruntime.Must(admissionregistrationv1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func TestCertificateRenewer(t *testing.T) {
+	opts := testOptions(t)
+	validating := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: opts.ValidatingWebhookConfiguration},
+		Webhooks:   []admissionregistrationv1.ValidatingWebhook{{Name: "vdashapplication.kb.io"}},
+	}
+	client := testClient(validating)
+	ctx := context.Background()
+	if err := EnsureCertificates(ctx, client, ctrl.Log, opts); err != nil {
+		t.Fatalf("EnsureCertificates() error = %v", err)
+	}
+	certFile := filepath.Join(opts.CertDir, corev1.TLSCertKey)
+	initial, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a certificate which is no longer valid for the service, e.g. expired, is replaced
+	secret := &corev1.Secret{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: opts.Namespace, Name: opts.SecretName}, secret); err != nil {
+		t.Fatal(err)
+	}
+	other := opts
+	other.ServiceName = "other"
+	if secret.Data, err = generateCertificates(other, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	renewer := &CertificateRenewer{Client: client, Log: ctrl.Log, Options: opts, Interval: 10 * time.Millisecond}
+	done := make(chan error, 1)
+	go func() { done <- renewer.Start(runCtx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if current, _ := os.ReadFile(certFile); !bytes.Equal(current, initial) && !bytes.Equal(current, secret.Data[corev1.TLSCertKey]) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKeyFromObject(secret), secret); err != nil {
+		t.Fatal(err)
+	}
+	if !validCertificate(secret, opts) {
+		t.Fatal("certificate was not renewed")
+	}
+	current, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(current, secret.Data[corev1.TLSCertKey]) {
+		t.Error("renewed certificate was not written to the certificate directory")
+	}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKeyFromObject(validating), validating); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(validating.Webhooks[0].ClientConfig.CABundle, secret.Data[caCertKey]) {
+		t.Error("CA bundle of the renewed certificate was not injected")
+	}
+}
+
+func TestEnsureCertificatesKeepsValidCertificate(t *testing.T) {
+	opts := testOptions(t)
+	client := testClient()
+	ctx := context.Background()
+	if err := EnsureCertificates(ctx, client, ctrl.Log, opts); err != nil {
+		t.Fatalf("EnsureCertificates() error = %v", err)
+	}
+	key := ctrlruntimeclient.ObjectKey{Namespace: opts.Namespace, Name: opts.SecretName}
+	before := &corev1.Secret{}
+	if err := client.Get(ctx, key, before); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EnsureCertificates(ctx, client, ctrl.Log, opts); err != nil {
+		t.Fatalf("EnsureCertificates() error = %v", err)
+	}
+	after := &corev1.Secret{}
+	if err := client.Get(ctx, key, after); err != nil {
+		t.Fatal(err)
+	}
+	if after.ResourceVersion != before.ResourceVersion {
+		t.Error("valid certificate was renewed")
+	}
+}
+
+// verifies reports whether the serving certificate is trusted by the CA bundle.
+func verifies(t *testing.T, certPEM, caBundle []byte) bool {
+	t.Helper()
+	cert := firstCertificate(certPEM)
+	if cert == nil {
+		t.Fatal("no certificate")
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caBundle) {
+		t.Fatal("no CA certificate")
+	}
+	_, err := cert.Verify(x509.VerifyOptions{Roots: roots})
+	return err == nil
+}
+
+func TestRenewalKeepsCA(t *testing.T) {
+	opts := testOptions(t)
+	client := testClient()
+	ctx := context.Background()
+	if err := EnsureCertificates(ctx, client, ctrl.Log, opts); err != nil {
+		t.Fatalf("EnsureCertificates() error = %v", err)
+	}
+	secret := &corev1.Secret{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: opts.Namespace, Name: opts.SecretName}, secret); err != nil {
+		t.Fatal(err)
+	}
+	before := secret.Data
+
+	// a serving certificate which has to be renewed, signed by the stored CA
+	other := opts
+	other.ServiceName = "other"
+	data, err := generateCertificates(other, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.Data = data
+	if err := client.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureCertificates(ctx, client, ctrl.Log, opts); err != nil {
+		t.Fatalf("EnsureCertificates() error = %v", err)
+	}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKeyFromObject(secret), secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if !validCertificate(secret, opts) {
+		t.Fatal("certificate was not renewed")
+	}
+	for _, key := range []string{caCertKey, caKeyKey} {
+		if !bytes.Equal(secret.Data[key], before[key]) {
+			t.Errorf("%s changed on renewal", key)
+		}
+	}
+	// replicas which didn't pick up the renewed certificate yet are still trusted
+	if !verifies(t, before[corev1.TLSCertKey], secret.Data[caCertKey]) {
+		t.Error("previous serving certificate is not trusted by the CA bundle")
+	}
+}
+
+func TestNewCAKeepsPreviousCA(t *testing.T) {
+	opts := testOptions(t)
+	previous, err := generateCertificates(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// created by a previous controller version, which didn't store the CA key
+	delete(previous, caKeyKey)
+
+	data, err := generateCertificates(opts, previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(firstCertificate(data[caCertKey]).Raw, firstCertificate(previous[caCertKey]).Raw) {
+		t.Fatal("CA without stored key was reused")
+	}
+	for name, certPEM := range map[string][]byte{"previous": previous[corev1.TLSCertKey], "new": data[corev1.TLSCertKey]} {
+		if !verifies(t, certPEM, data[caCertKey]) {
+			t.Errorf("%s serving certificate is not trusted by the CA bundle", name)
+		}
+	}
+	if cert, key := storedCA(data, time.Now()); cert == nil || key == nil {
+		t.Error("new CA can't be reused")
+	}
+}